@@ -180,6 +180,8 @@ pub enum ParsedType {
         name: String,
         type_parameters: Vec<ParsedType>,
     },
+    /// `(int, int)`
+    Tuple(Vec<ParsedType>),
 }
 
 impl ParsedType {
@@ -192,16 +194,23 @@ impl ParsedType {
             type_parameters,
         }
     }
+    pub fn tuple(element_types: Vec<ParsedType>) -> ParsedType {
+        ParsedType::Tuple(element_types)
+    }
 
     pub fn name(&self) -> String {
         match self {
             ParsedType::TypeName(name) => name.clone(),
             ParsedType::GenericType { name, .. } => name.clone(),
+            ParsedType::Tuple(element_types) => {
+                let names: Vec<String> = element_types.iter().map(|t| t.name()).collect();
+                format!("({})", names.join(", "))
+            }
         }
     }
     pub fn generics(&self) -> Vec<ParsedType> {
         match self {
-            ParsedType::TypeName(_) => vec![],
+            ParsedType::TypeName(_) | ParsedType::Tuple(_) => vec![],
             ParsedType::GenericType {
                 type_parameters, ..
             } => type_parameters.clone(),
@@ -216,6 +225,8 @@ pub struct Variable {
     pub name: String,
     pub typ: ParsedType,
     pub expr: Expr,
+    /// mutable variable can be assigned again, e.g. `mut x: int = 1;`
+    pub mutable: bool,
 }
 
 impl Variable {
@@ -232,6 +243,7 @@ impl Variable {
             name: name.to_string(),
             typ,
             expr,
+            mutable: false,
         }
     }
 }
@@ -311,13 +323,15 @@ impl Block {
 
 #[derive(Clone, Debug, PartialEq)]
 pub struct Parameter {
+    pub location: Location,
     pub name: String,
     pub typ: ParsedType,
 }
 
 impl Parameter {
-    pub fn new<T: ToString>(name: T, typ: ParsedType) -> Parameter {
+    pub fn new<T: ToString>(location: Location, name: T, typ: ParsedType) -> Parameter {
         Parameter {
+            location,
             name: name.to_string(),
             typ,
         }
@@ -343,6 +357,12 @@ impl Statement {
             value: StatementVariant::Variable(variable),
         }
     }
+    pub fn assign<T: ToString>(location: Location, name: T, expr: Expr) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::Assign(name.to_string(), expr),
+        }
+    }
     pub fn expression(location: Location, expr: Expr) -> Statement {
         Statement {
             location,
@@ -362,6 +382,41 @@ impl Statement {
             },
         }
     }
+    pub fn loop_block(location: Location, block: Block) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::Loop(block),
+        }
+    }
+    pub fn while_block(location: Location, condition: Expr, block: Block) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::While(condition, block),
+        }
+    }
+    pub fn break_stmt(location: Location) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::Break,
+        }
+    }
+    pub fn continue_stmt(location: Location) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::Continue,
+        }
+    }
+    pub fn destructure(
+        location: Location,
+        names: Vec<String>,
+        typ: ParsedType,
+        expr: Expr,
+    ) -> Statement {
+        Statement {
+            location,
+            value: StatementVariant::Destructure(names, typ, expr),
+        }
+    }
 }
 
 #[derive(Clone, Debug, PartialEq)]
@@ -370,6 +425,10 @@ pub enum StatementVariant {
     Return(Option<Expr>),
     /// `x: int = 1;`
     Variable(Variable),
+    /// `x = 2;`
+    Assign(String, Expr),
+    /// `(x, y): (int, int) = pair();`
+    Destructure(Vec<String>, ParsedType, Expr),
     /// `println("hello");`
     /// `foo.bar();`
     Expression(Expr),
@@ -378,6 +437,14 @@ pub enum StatementVariant {
         clauses: Vec<(Expr, Block)>,
         else_block: Block,
     },
+    /// `loop {}`
+    Loop(Block),
+    /// `while <condition> {}`
+    While(Expr, Block),
+    /// `break;`
+    Break,
+    /// `continue;`
+    Continue,
 }
 
 #[derive(Clone, Debug, PartialEq)]
@@ -393,6 +460,12 @@ impl Expr {
             value: ExprVariant::Binary(l.into(), r.into(), op),
         }
     }
+    pub fn unary(location: Location, op: UnaryOperator, e: Expr) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Unary(op, e.into()),
+        }
+    }
     pub fn f64(location: Location, f: f64) -> Expr {
         Expr {
             location,
@@ -411,6 +484,12 @@ impl Expr {
             value: ExprVariant::Bool(b),
         }
     }
+    pub fn char(location: Location, c: char) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Char(c),
+        }
+    }
     pub fn string<T: ToString>(location: Location, s: T) -> Expr {
         Expr {
             location,
@@ -423,6 +502,36 @@ impl Expr {
             value: ExprVariant::List(lst),
         }
     }
+    pub fn list_repeat(location: Location, element: Expr, count: usize) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::ListRepeat(element.into(), count),
+        }
+    }
+    pub fn tuple(location: Location, elements: Vec<Expr>) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Tuple(elements),
+        }
+    }
+    pub fn cast(location: Location, expr: Expr, typ: ParsedType) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Cast(expr.into(), typ),
+        }
+    }
+    pub fn match_expr(location: Location, expr: Expr, arms: Vec<MatchArm>) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Match(expr.into(), arms),
+        }
+    }
+    pub fn index(location: Location, list: Expr, index: Expr) -> Expr {
+        Expr {
+            location,
+            value: ExprVariant::Index(list.into(), index.into()),
+        }
+    }
     pub fn func_call(location: Location, expr: Expr, args: Vec<Argument>) -> Expr {
         Expr {
             location,
@@ -457,16 +566,30 @@ impl Expr {
 pub enum ExprVariant {
     /// `x + y`
     Binary(Box<Expr>, Box<Expr>, Operator),
+    /// `+x`
+    Unary(UnaryOperator, Box<Expr>),
     /// `1.345`
     F64(f64),
     /// `1`
     Int(i64),
     /// `true` or `false`
     Bool(bool),
+    /// `'c'`
+    Char(char),
     /// `"str"`
     String(String),
     /// `[1, 2, 3]`
     List(Vec<Expr>),
+    /// `[0; 4]`
+    ListRepeat(Box<Expr>, usize),
+    /// `(1, 2)`
+    Tuple(Vec<Expr>),
+    /// `xs[i]`
+    Index(Box<Expr>, Box<Expr>),
+    /// `x as f64`
+    Cast(Box<Expr>, ParsedType),
+    /// `match x { 1 => a, _ => b }`
+    Match(Box<Expr>, Vec<MatchArm>),
     /// `a(b)`
     FuncCall(Box<Expr>, Vec<Argument>),
     /// `foo.bar`, `foo.bar()`, `foo().bar`
@@ -477,6 +600,191 @@ pub enum ExprVariant {
     ClassConstruction(String, HashMap<String, Expr>),
 }
 
+impl ExprVariant {
+    fn kind(&self) -> &'static str {
+        use ExprVariant::*;
+        match self {
+            Binary(..) => "Binary",
+            Unary(..) => "Unary",
+            F64(..) => "F64",
+            Int(..) => "Int",
+            Bool(..) => "Bool",
+            Char(..) => "Char",
+            String(..) => "String",
+            List(..) => "List",
+            ListRepeat(..) => "ListRepeat",
+            Tuple(..) => "Tuple",
+            Index(..) => "Index",
+            Cast(..) => "Cast",
+            Match(..) => "Match",
+            FuncCall(..) => "FuncCall",
+            MemberAccess(..) => "MemberAccess",
+            Identifier(..) => "Identifier",
+            ClassConstruction(..) => "ClassConstruction",
+        }
+    }
+}
+
+/// diff describes the first structural difference between two expressions, e.g.
+/// `expr.rhs: expected `Int(3)` but got `Int(2)``, returns None when they are equal.
+///
+/// It makes failed assertion on a big expression tree easier to read.
+pub fn diff(expected: &Expr, actual: &Expr) -> Option<String> {
+    diff_expr("expr".to_string(), expected, actual)
+}
+
+fn diff_expr(path: String, expected: &Expr, actual: &Expr) -> Option<String> {
+    use ExprVariant::*;
+    let result = match (&expected.value, &actual.value) {
+        (e, a) if e.kind() != a.kind() => Some(format!(
+            "{}: expected {} node but got {} node",
+            path,
+            e.kind(),
+            a.kind()
+        )),
+        (Binary(l1, r1, op1), Binary(l2, r2, op2)) => {
+            if op1 != op2 {
+                mismatched(&path, op1, op2)
+            } else {
+                diff_expr(format!("{}.lhs", path), l1, l2)
+                    .or_else(|| diff_expr(format!("{}.rhs", path), r1, r2))
+            }
+        }
+        (Unary(op1, e1), Unary(op2, e2)) => {
+            if op1 != op2 {
+                mismatched(&path, op1, op2)
+            } else {
+                diff_expr(format!("{}.operand", path), e1, e2)
+            }
+        }
+        (List(l1), List(l2)) | (Tuple(l1), Tuple(l2)) => diff_expr_list(&path, l1, l2),
+        (ListRepeat(e1, n1), ListRepeat(e2, n2)) => {
+            if n1 != n2 {
+                mismatched(&format!("{}.count", path), n1, n2)
+            } else {
+                diff_expr(format!("{}.element", path), e1, e2)
+            }
+        }
+        (Index(list1, index1), Index(list2, index2)) => {
+            diff_expr(format!("{}.list", path), list1, list2)
+                .or_else(|| diff_expr(format!("{}.index", path), index1, index2))
+        }
+        (Cast(e1, t1), Cast(e2, t2)) => {
+            if t1 != t2 {
+                mismatched(&format!("{}.type", path), t1, t2)
+            } else {
+                diff_expr(format!("{}.expr", path), e1, e2)
+            }
+        }
+        (Match(e1, arms1), Match(e2, arms2)) => diff_expr(format!("{}.expr", path), e1, e2)
+            .or_else(|| {
+                if arms1.len() != arms2.len() {
+                    return mismatched(&format!("{}.arms", path), arms1.len(), arms2.len());
+                }
+                arms1
+                    .iter()
+                    .zip(arms2.iter())
+                    .enumerate()
+                    .find_map(|(i, (a1, a2))| {
+                        let path = format!("{}.arms[{}]", path, i);
+                        match (&a1.pattern, &a2.pattern) {
+                            (Some(p1), Some(p2)) => diff_expr(format!("{}.pattern", path), p1, p2),
+                            (None, None) => None,
+                            (p1, p2) => mismatched(&format!("{}.pattern", path), p1, p2),
+                        }
+                        .or_else(|| diff_expr(format!("{}.expr", path), &a1.expr, &a2.expr))
+                    })
+            }),
+        (FuncCall(f1, args1), FuncCall(f2, args2)) => diff_expr(format!("{}.func", path), f1, f2)
+            .or_else(|| {
+                let names1: Vec<_> = args1.iter().map(|arg| &arg.name).collect();
+                let names2: Vec<_> = args2.iter().map(|arg| &arg.name).collect();
+                if names1 != names2 {
+                    mismatched(&format!("{}.args", path), names1, names2)
+                } else {
+                    let args1: Vec<_> = args1.iter().map(|arg| arg.expr.clone()).collect();
+                    let args2: Vec<_> = args2.iter().map(|arg| arg.expr.clone()).collect();
+                    diff_expr_list(&format!("{}.args", path), &args1, &args2)
+                }
+            }),
+        (MemberAccess(from1, access1), MemberAccess(from2, access2)) => {
+            if access1 != access2 {
+                mismatched(&format!("{}.access", path), access1, access2)
+            } else {
+                diff_expr(format!("{}.from", path), from1, from2)
+            }
+        }
+        (ClassConstruction(name1, inits1), ClassConstruction(name2, inits2)) => {
+            let mut fields1: Vec<_> = inits1.keys().collect();
+            let mut fields2: Vec<_> = inits2.keys().collect();
+            fields1.sort();
+            fields2.sort();
+            if name1 != name2 {
+                mismatched(&format!("{}.class", path), name1, name2)
+            } else if fields1 != fields2 {
+                mismatched(&format!("{}.fields", path), fields1, fields2)
+            } else {
+                fields1.iter().find_map(|field| {
+                    diff_expr(
+                        format!("{}.{}", path, field),
+                        &inits1[*field],
+                        &inits2[*field],
+                    )
+                })
+            }
+        }
+        // literal and identifier
+        (e, a) if e != a => mismatched(&path, e, a),
+        _ => None,
+    };
+    result.or_else(|| {
+        if expected.location != actual.location {
+            Some(format!(
+                "{}: expected location `{}` but got `{}`",
+                path, expected.location, actual.location
+            ))
+        } else {
+            None
+        }
+    })
+}
+
+fn diff_expr_list(path: &String, expected: &Vec<Expr>, actual: &Vec<Expr>) -> Option<String> {
+    if expected.len() != actual.len() {
+        return Some(format!(
+            "{}: expected {} elements but got {}",
+            path,
+            expected.len(),
+            actual.len()
+        ));
+    }
+    expected
+        .iter()
+        .zip(actual.iter())
+        .enumerate()
+        .find_map(|(i, (e, a))| diff_expr(format!("{}[{}]", path, i), e, a))
+}
+
+fn mismatched<T: std::fmt::Debug>(path: &String, expected: T, actual: T) -> Option<String> {
+    Some(format!(
+        "{}: expected `{:?}` but got `{:?}`",
+        path, expected, actual
+    ))
+}
+
+/// MatchArm is `<pattern> => <expr>` in match, the pattern of the default arm `_` is `None`
+#[derive(Clone, Debug, PartialEq)]
+pub struct MatchArm {
+    pub pattern: Option<Expr>,
+    pub expr: Expr,
+}
+
+impl MatchArm {
+    pub fn new(pattern: Option<Expr>, expr: Expr) -> MatchArm {
+        MatchArm { pattern, expr }
+    }
+}
+
 /// Argument:
 ///
 /// `assert(n, equal_to: 1)`
@@ -500,13 +808,106 @@ impl Argument {
 #[derive(Clone, Debug, PartialEq)]
 pub enum Operator {
     Plus,
+    Minus,
+    Multiply,
+    Divide,
+    Remainder,
+    Pow,
+    Equal,
+    NotEqual,
+    LessThan,
+    LessThanOrEqual,
+    GreaterThan,
+    GreaterThanOrEqual,
+    And,
+    Or,
 }
 
 impl Operator {
     pub fn from_token(token: Token) -> Operator {
         match token.tk_type() {
             TkType::Plus => Operator::Plus,
+            TkType::Minus => Operator::Minus,
+            TkType::Multiple => Operator::Multiply,
+            TkType::Divide => Operator::Divide,
+            TkType::Percent => Operator::Remainder,
+            TkType::Caret => Operator::Pow,
+            TkType::EqualEqual => Operator::Equal,
+            TkType::NotEqual => Operator::NotEqual,
+            TkType::LessThan => Operator::LessThan,
+            TkType::LessThanOrEqual => Operator::LessThanOrEqual,
+            TkType::GreaterThan => Operator::GreaterThan,
+            TkType::GreaterThanOrEqual => Operator::GreaterThanOrEqual,
+            TkType::AndAnd => Operator::And,
+            TkType::OrOr => Operator::Or,
             tok => unimplemented!("{:?} is not a operator", tok),
         }
     }
+    /// is_comparison is true if the operator produces a `bool`
+    pub fn is_comparison(&self) -> bool {
+        use Operator::*;
+        match self {
+            Plus | Minus | Multiply | Divide | Remainder | Pow | And | Or => false,
+            Equal | NotEqual | LessThan | LessThanOrEqual | GreaterThan | GreaterThanOrEqual => {
+                true
+            }
+        }
+    }
+    /// is_logical is true for `&&` and `||`, the right operand is evaluated only when the left
+    /// one can't decide the result
+    pub fn is_logical(&self) -> bool {
+        match self {
+            Operator::And | Operator::Or => true,
+            _ => false,
+        }
+    }
+}
+
+impl std::fmt::Display for Operator {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        match self {
+            Operator::Plus => write!(f, "+"),
+            Operator::Minus => write!(f, "-"),
+            Operator::Multiply => write!(f, "*"),
+            Operator::Divide => write!(f, "/"),
+            Operator::Remainder => write!(f, "%"),
+            Operator::Pow => write!(f, "^"),
+            Operator::Equal => write!(f, "=="),
+            Operator::NotEqual => write!(f, "!="),
+            Operator::LessThan => write!(f, "<"),
+            Operator::LessThanOrEqual => write!(f, "<="),
+            Operator::GreaterThan => write!(f, ">"),
+            Operator::GreaterThanOrEqual => write!(f, ">="),
+            Operator::And => write!(f, "&&"),
+            Operator::Or => write!(f, "||"),
+        }
+    }
+}
+
+#[derive(Clone, Debug, PartialEq)]
+pub enum UnaryOperator {
+    Plus,
+    Minus,
+    Not,
+}
+
+impl UnaryOperator {
+    pub fn from_token(token: Token) -> UnaryOperator {
+        match token.tk_type() {
+            TkType::Plus => UnaryOperator::Plus,
+            TkType::Minus => UnaryOperator::Minus,
+            TkType::Not => UnaryOperator::Not,
+            tok => unimplemented!("{:?} is not a unary operator", tok),
+        }
+    }
+}
+
+impl std::fmt::Display for UnaryOperator {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        match self {
+            UnaryOperator::Plus => write!(f, "+"),
+            UnaryOperator::Minus => write!(f, "-"),
+            UnaryOperator::Not => write!(f, "!"),
+        }
+    }
 }
@@ -1,65 +1,248 @@
-use crate::ast::{Import, TopAst};
+use crate::ast::{Import, Module, TopAst};
+use crate::codegen::ir;
 use crate::codegen::llvm::LLVMValue;
+use crate::codegen::source_map::source_map;
 use crate::codegen::CodeGenerator;
 use crate::diagnostic::Reporter;
-use crate::lexer::Location;
+use crate::lexer::{lex, Location};
 use crate::parser::{parse_prelude, Parser};
+use crate::semantic::condition::check_conditions;
+use crate::semantic::naming::{check_naming, NamingPolicy};
 use crate::semantic::SemanticChecker;
+use std::collections::HashMap;
+use std::io::Write;
+use std::process::{Command, Stdio};
 
 pub const CMD_NAME: &'static str = "compile";
 
-pub fn compile(files: Vec<&str>) -> Result<(), Box<dyn std::error::Error>> {
+/// compile reports naming warnings only when a naming policy is given, writes source map to
+/// the given file, and writes a native object file instead of printing LLVM IR when output is
+/// given, LLVM IR is optimized by `opt` when optimization level is not 0, and has DWARF line
+/// tables when debug info is wanted
+pub fn compile(
+    files: Vec<&str>,
+    naming_policy: Option<NamingPolicy>,
+    source_map_file: Option<&str>,
+    output: Option<&str>,
+    opt_level: u32,
+    debug_info: bool,
+) -> Result<(), Box<dyn std::error::Error>> {
     let mut reporter = Reporter::new();
-    // FIXME: comment out code generator for now to focus on semantic checking
-    let program = check(&mut reporter, files)?;
-    let code_generator = CodeGenerator::new();
-    let module = code_generator.generate_module(&program);
-    println!("{}", module.llvm_represent());
+    let mut sources = vec![];
+    for file in files.iter() {
+        sources.push((file.to_string(), std::fs::read_to_string(file)?));
+    }
+    let module = build(&mut reporter, sources, naming_policy);
+    reporter.emit();
+    let mut module = module?;
+    module.debug_info = debug_info;
+    if let Some(file) = source_map_file {
+        std::fs::write(file, source_map(&module))?;
+    }
+    match output {
+        Some(output) => emit_object(module, opt_level, output),
+        None => {
+            println!("{}", optimize(&module.llvm_represent(), opt_level)?);
+            Ok(())
+        }
+    }
+}
+
+/// llc is the command to run `llc`, it can be set by environment variable `LLC`, e.g.
+/// `LLC=llc-14`
+pub(crate) fn llc() -> String {
+    std::env::var("LLC").unwrap_or("llc".to_string())
+}
+/// opt is the command to run `opt`, it can be set by environment variable `OPT`
+pub(crate) fn opt() -> String {
+    std::env::var("OPT").unwrap_or("opt".to_string())
+}
+
+/// emit_object writes the module as a native object file by `llc`, which also verifies the
+/// module, the module must have a `main` function as entry point
+pub(crate) fn emit_object(
+    mut module: ir::Module,
+    opt_level: u32,
+    output: &str,
+) -> Result<(), Box<dyn std::error::Error>> {
+    if !module.set_entry_point() {
+        return Err("no `main` function as entry point".into());
+    }
+    let ir = optimize(&module.llvm_represent(), opt_level)?;
+    run_llvm_tool(llc(), &["-filetype=obj", "-o", output], &ir)?;
     Ok(())
 }
 
-fn check(
+/// optimize runs the pass pipeline of `opt -O<level>` on LLVM IR, e.g. `-O1` has mem2reg,
+/// instcombine and simplifycfg, higher level adds more passes, level 0 returns IR unchanged
+pub(crate) fn optimize(ir: &str, level: u32) -> Result<String, Box<dyn std::error::Error>> {
+    if level == 0 {
+        return Ok(ir.to_string());
+    }
+    let output = run_llvm_tool(opt(), &["-S", format!("-O{}", level).as_str()], ir)?;
+    Ok(String::from_utf8(output)?)
+}
+
+/// run_llvm_tool runs the tool with LLVM IR as stdin and returns its stdout
+fn run_llvm_tool(
+    program: String,
+    args: &[&str],
+    ir: &str,
+) -> Result<Vec<u8>, Box<dyn std::error::Error>> {
+    let mut tool = Command::new(&program)
+        .args(args)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|err| format!("cannot run `{}`: {}", program, err))?;
+    tool.stdin
+        .take()
+        .expect("stdin is piped")
+        .write_all(ir.as_bytes())?;
+    let result = tool.wait_with_output()?;
+    if !result.status.success() {
+        return Err(format!(
+            "`{}` failed: {}",
+            program,
+            String::from_utf8_lossy(&result.stderr).trim()
+        )
+        .into());
+    }
+    Ok(result.stdout)
+}
+
+/// build checks sources and generates the module, all input files are compiled into one module
+/// which named by the first file, the module can be inspected without parsing its LLVM IR
+pub(crate) fn build(
     reporter: &mut Reporter,
-    files: Vec<&str>,
+    sources: Vec<(String, String)>,
+    naming_policy: Option<NamingPolicy>,
+) -> Result<ir::Module, Box<dyn std::error::Error>> {
+    let module_name = sources[0].0.clone();
+    let program = check(reporter, sources.clone(), naming_policy)?;
+    let code_generator = CodeGenerator::new();
+    let module = code_generator.generate_module(&module_name, &program);
+    if module.errors.is_empty() {
+        return Ok(module);
+    }
+    for (file_name, code) in &sources {
+        let mut file_reporter = reporter.for_file(file_name, code);
+        for err in &module.errors {
+            if err.location().file_name() == file_name {
+                file_reporter.add_diagnostic(err.location(), format!("{}", err), err.message());
+            }
+        }
+        file_reporter.report(reporter);
+    }
+    Err(module.errors[0].clone().into())
+}
+
+/// check merges top level definitions of all sources(file name, code) into one module, so a
+/// function can refer another defined in other file. All sources must declare the same module,
+/// errors of parsing are collected from every file before giving up
+pub(crate) fn check(
+    reporter: &mut Reporter,
+    sources: Vec<(String, String)>,
+    naming_policy: Option<NamingPolicy>,
 ) -> Result<Vec<TopAst>, Box<dyn std::error::Error>> {
-    // FIXME: for now to make code simple we only handle the first input file.
-    let code = std::fs::read_to_string(files[0])?;
-    let mut file_reporter = reporter.for_file(files[0], &code);
-    let mut module = match Parser::parse_program(files[0], &code) {
-        Ok(p) => p,
-        Err(err) => {
-            file_reporter.add_diagnostic(err.location(), format!("{}", err), err.message());
-            file_reporter.report(reporter);
-            return Err(err.into());
+    let mut file_reporters = HashMap::new();
+    let mut module: Option<(Module, &String)> = None;
+    let mut first_error: Option<Box<dyn std::error::Error>> = None;
+    for (file_name, code) in &sources {
+        let mut file_reporter = reporter.for_file(file_name, code);
+        match Parser::parse_program(file_name, code) {
+            Ok(mut file_module) => {
+                for warning in check_conditions(&file_module) {
+                    file_reporter.add_warning(warning.location.clone(), format!("{}", warning));
+                }
+                if let Some(policy) = &naming_policy {
+                    for warning in check_naming(&file_module, policy) {
+                        file_reporter.add_warning(warning.location.clone(), format!("{}", warning));
+                    }
+                }
+                match &mut module {
+                    None => module = Some((file_module, file_name)),
+                    Some((module, first_file)) if module.name != file_module.name => {
+                        let message = format!(
+                            "module `{}` differs from module `{}` of `{}`",
+                            file_module.name, module.name, first_file
+                        );
+                        file_reporter.add_diagnostic(
+                            module_name_location(file_name, code),
+                            message.clone(),
+                            "different module".to_string(),
+                        );
+                        first_error.get_or_insert(message.into());
+                    }
+                    Some((module, _)) => module.top_list.append(&mut file_module.top_list),
+                }
+            }
+            Err(err) => {
+                file_reporter.add_diagnostic(err.location(), format!("{}", err), err.message());
+                first_error.get_or_insert(err.into());
+            }
         }
-    };
+        file_reporters.insert(file_name.clone(), file_reporter);
+    }
+    if let Some(err) = first_error {
+        for (file_name, _) in &sources {
+            file_reporters[file_name].report(reporter);
+        }
+        return Err(err);
+    }
+    let (mut module, _) = module.expect("at least one input file");
     // insert import prelude
     module.top_list.push(TopAst::Import(Import {
         location: Location::none(),
         import_path: "prelude".to_string(),
         imported_component: vec![
             "int".to_string(),
+            "i8".to_string(),
+            "i16".to_string(),
+            "i32".to_string(),
             "void".to_string(),
             "f64".to_string(),
+            "f32".to_string(),
             "bool".to_string(),
+            "char".to_string(),
             "string".to_string(),
             "List".to_string(),
             "println".to_string(),
+            "print".to_string(),
         ],
     }));
 
     let prelude = parse_prelude();
+    // codegen takes all definitions as one list, clone here since semantic checker still needs
+    // modules
     let mut l = prelude.top_list.clone();
-    l.append(module.top_list.as_mut());
+    l.extend(module.top_list.iter().cloned());
     let program = vec![prelude, module];
     // check program
     let mut semantic_checker = SemanticChecker::new();
-    match semantic_checker.check_program(&program) {
-        Ok(..) => Ok(l),
-        Err(err) => {
-            file_reporter.add_diagnostic(err.location(), format!("{}", err), err.message());
-            file_reporter.report(reporter);
-            Err(err.into())
+    let result = semantic_checker.check_program(&program);
+    if let Err(err) = &result {
+        let location = err.location();
+        // report to the file where error happened
+        if let Some(file_reporter) = file_reporters.get_mut(location.file_name()) {
+            file_reporter.add_diagnostic(location, format!("{}", err), err.message());
         }
     }
+    for (file_name, _) in &sources {
+        file_reporters[file_name].report(reporter);
+    }
+    match result {
+        Ok(..) => Ok(l),
+        Err(err) => Err(err.into()),
+    }
+}
+
+/// module_name_location is the location of module name in `module <name>`, which must be the
+/// first statement of a file
+fn module_name_location(file_name: &str, code: &str) -> Location {
+    lex(file_name, code)
+        .get(1)
+        .map(|tok| tok.location())
+        .unwrap_or(Location::none())
 }
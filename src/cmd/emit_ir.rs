@@ -0,0 +1,27 @@
+use super::compile::build;
+use crate::codegen::llvm::LLVMValue;
+use crate::diagnostic::Reporter;
+use std::io::Read;
+
+pub const CMD_NAME: &'static str = "emit-ir";
+
+/// emit_ir writes LLVM IR of the input file to output, input `-` reads source from stdin, and
+/// prints LLVM IR when no output is given
+pub fn emit_ir(input: &str, output: Option<&str>) -> Result<(), Box<dyn std::error::Error>> {
+    let (file_name, code) = if input == "-" {
+        let mut code = String::new();
+        std::io::stdin().read_to_string(&mut code)?;
+        ("<stdin>".to_string(), code)
+    } else {
+        (input.to_string(), std::fs::read_to_string(input)?)
+    };
+    let mut reporter = Reporter::new();
+    let module = build(&mut reporter, vec![(file_name, code)], None);
+    reporter.emit();
+    let ir = module?.llvm_represent();
+    match output {
+        Some(output) => std::fs::write(output, ir)?,
+        None => println!("{}", ir),
+    }
+    Ok(())
+}
@@ -1,2 +1,7 @@
 pub mod compile;
+pub mod emit_ir;
 pub mod fmt;
+pub mod run;
+
+#[cfg(test)]
+mod tests;
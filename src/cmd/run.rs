@@ -0,0 +1,54 @@
+use super::compile::build;
+use crate::codegen::ir::{Module, Type};
+use crate::codegen::llvm::LLVMValue;
+use crate::diagnostic::Reporter;
+use std::io::Write;
+use std::process::{Command, Stdio};
+
+pub const CMD_NAME: &'static str = "run";
+
+/// lli is the command to run `lli`, it can be set by environment variable `LLI`
+pub(crate) fn lli() -> String {
+    std::env::var("LLI").unwrap_or("lli".to_string())
+}
+
+/// run executes `main` of the input file by the JIT of `lli`, returns the exit code of the
+/// program, which is the value returned by `main(): int`, or 0 for `main(): void`
+pub fn run(input: &str) -> Result<i32, Box<dyn std::error::Error>> {
+    let code = std::fs::read_to_string(input)?;
+    let mut reporter = Reporter::new();
+    let module = build(&mut reporter, vec![(input.to_string(), code)], None);
+    reporter.emit();
+    let mut module = module?;
+    check_main(&module)?;
+    module.set_entry_point();
+    let mut program = Command::new(lli())
+        .stdin(Stdio::piped())
+        .spawn()
+        .map_err(|err| format!("cannot run `{}`: {}", lli(), err))?;
+    program
+        .stdin
+        .take()
+        .expect("stdin is piped")
+        .write_all(module.llvm_represent().as_bytes())?;
+    let status = program.wait()?;
+    // killed by signal has no exit code
+    status
+        .code()
+        .ok_or_else(|| format!("program terminated by {}", status).into())
+}
+
+/// check_main ensures the module has `main(): int`, `main(): i32` or `main(): void`
+pub(crate) fn check_main(module: &Module) -> Result<(), Box<dyn std::error::Error>> {
+    let main = module
+        .function("main")
+        .ok_or("no `main` function as entry point")?;
+    let ret_type_ok = match main.ret_typ {
+        Type::Void | Type::Int(32) | Type::Int(64) => true,
+        _ => false,
+    };
+    if !main.parameters.is_empty() || !ret_type_ok {
+        return Err("`main` must have no parameters and return `int`, `i32` or `void`".into());
+    }
+    Ok(())
+}
@@ -0,0 +1,317 @@
+use super::compile::{build, check, emit_object, llc, opt, optimize};
+use super::emit_ir::emit_ir;
+use super::run::{check_main, lli, run};
+use crate::codegen::llvm::LLVMValue;
+use crate::codegen::source_map::source_map;
+use crate::diagnostic::Reporter;
+
+#[test]
+fn function_can_call_function_defined_in_other_file() {
+    let mut reporter = Reporter::new();
+    let sources = vec![
+        (
+            "main.elz".to_string(),
+            "module main\nmain(): void { hello(); }".to_string(),
+        ),
+        (
+            "hello.elz".to_string(),
+            "module main\nhello(): void { println(\"hello\"); }".to_string(),
+        ),
+    ];
+    let result = check(&mut reporter, sources, None);
+    assert_eq!(result.is_ok(), true);
+    assert_eq!(reporter.has_errors(), false);
+}
+
+#[test]
+fn same_name_in_different_files() {
+    let mut reporter = Reporter::new();
+    let sources = vec![
+        (
+            "a.elz".to_string(),
+            "module main\nfoo(): void {}".to_string(),
+        ),
+        (
+            "b.elz".to_string(),
+            "module main\nfoo(): void {}".to_string(),
+        ),
+    ];
+    let result = check(&mut reporter, sources, None);
+    assert_eq!(result.is_err(), true);
+    assert_eq!(reporter.has_errors(), true);
+}
+
+#[test]
+fn parse_errors_are_collected_from_all_files() {
+    let mut reporter = Reporter::new();
+    let sources = vec![
+        (
+            "a.elz".to_string(),
+            "module main\nfoo(): void {}".to_string(),
+        ),
+        (
+            "b.elz".to_string(),
+            "module main\nbar(: void {}".to_string(),
+        ),
+        (
+            "c.elz".to_string(),
+            "module main\nbaz() void {}".to_string(),
+        ),
+    ];
+    let result = check(&mut reporter, sources, None);
+    assert_eq!(result.is_err(), true);
+    let files: Vec<_> = reporter
+        .messages()
+        .iter()
+        .map(|message| message.split(':').next().unwrap().to_string())
+        .collect();
+    assert_eq!(files, vec!["b.elz", "c.elz"]);
+}
+
+#[test]
+fn different_module_names_are_reported() {
+    let mut reporter = Reporter::new();
+    let sources = vec![
+        (
+            "main.elz".to_string(),
+            "module main\nmain(): void {}".to_string(),
+        ),
+        (
+            "lib.elz".to_string(),
+            "module lib\nfoo(): void {}".to_string(),
+        ),
+    ];
+    let result = check(&mut reporter, sources, None);
+    assert_eq!(result.is_err(), true);
+    assert_eq!(
+        reporter.messages(),
+        vec!["lib.elz:1:7: module `lib` differs from module `main` of `main.elz`"]
+    );
+}
+
+#[test]
+fn continue_outside_of_loop_is_reported() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nmain(): void { continue; }".to_string(),
+    )];
+    let result = check(&mut reporter, sources, None);
+    assert_eq!(result.is_err(), true);
+    assert_eq!(reporter.has_errors(), true);
+}
+
+#[test]
+fn inspect_built_module() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nadd(x: int, y: int): int = x + y;\nmain(): void {}".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    assert_eq!(module.function("main").unwrap().parameters.len(), 0);
+    assert_eq!(module.function("add").unwrap().parameters.len(), 2);
+    assert_eq!(module.function("not_exist").is_none(), true);
+}
+
+#[test]
+fn pow_on_runtime_value_calls_prelude() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\npow(a: int, b: int): int = a ^ b;".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    assert_eq!(reporter.has_errors(), false);
+    assert_eq!(
+        module.function("pow").unwrap().llvm_represent(),
+        "define i64 @pow(i64 %a, i64 %b) {
+  %1 = call i64 @_pow_int(i64 %a, i64 %b)
+  ret i64 %1
+}"
+    );
+    assert_eq!(module.function("_pow_int").unwrap().body.is_some(), true);
+}
+
+#[test]
+fn pow_int_with_large_exponent() {
+    if !llvm_available(lli()) {
+        return;
+    }
+    let input = temp_file("pow_int_with_large_exponent.elz");
+    let code = "module main
+p(b: int, e: int): int = b ^ e;
+main(): int {
+  min: int = -9223372036854775807 - 1;
+  if p(1, 9223372036854775807) == 1 && p(-1, 9223372036854775807) == -1 && p(2, 62) == 4611686018427387904 && p(3, min) == 0 && p(-1, min) == 1 && p(0, -1) == 0 && p(0, min) == 0 {
+    return 42;
+  }
+  return 1;
+}";
+    std::fs::write(&input, code).unwrap();
+    assert_eq!(run(input.to_str().unwrap()).unwrap(), 42);
+    std::fs::remove_file(input).unwrap();
+}
+
+#[test]
+fn source_map_of_built_module() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nx: int = 1;\n\nmain(): void {\n  println(\"hi\");\n}".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    let map = source_map(&module);
+    assert_eq!(
+        map.contains(r#"{"symbol": "@main", "file": "main.elz", "line": 4, "column": 0}"#),
+        true
+    );
+    assert_eq!(
+        map.contains(r#"{"symbol": "@x", "file": "main.elz", "line": 2, "column": 0}"#),
+        true
+    );
+}
+
+#[test]
+fn emit_object_of_main() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nmain(): void {}".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    if !llvm_available(llc()) {
+        return;
+    }
+    let output = temp_file("emit_object_of_main.o");
+    emit_object(module, 0, output.to_str().unwrap()).unwrap();
+    assert_eq!(std::fs::metadata(&output).unwrap().len() > 0, true);
+    std::fs::remove_file(output).unwrap();
+}
+
+#[test]
+fn emit_object_without_main() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nfoo(): void {}".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    let output = temp_file("emit_object_without_main.o");
+    let err = emit_object(module, 0, output.to_str().unwrap()).unwrap_err();
+    assert_eq!(err.to_string(), "no `main` function as entry point");
+}
+
+#[test]
+fn optimize_folds_constant() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\ntwo(): int { return 1 + 1; }".to_string(),
+    )];
+    let ir = build(&mut reporter, sources, None)
+        .unwrap()
+        .llvm_represent();
+    assert_eq!(optimize(&ir, 0).unwrap(), ir);
+    assert_eq!(ir.contains("add i64 1, 1"), true);
+    if !llvm_available(opt()) {
+        return;
+    }
+    let optimized = optimize(&ir, 1).unwrap();
+    let two = optimized.split("@two()").nth(1).unwrap();
+    let two = &two[..two.find('}').unwrap()];
+    assert_eq!(two.contains("ret i64 2"), true);
+    assert_eq!(two.contains("add i64"), false);
+}
+
+#[test]
+fn emit_ir_to_file() {
+    let input = temp_file("emit_ir_to_file.elz");
+    let output = temp_file("emit_ir_to_file.ll");
+    std::fs::write(&input, "module main\nmain(): void {}").unwrap();
+    emit_ir(input.to_str().unwrap(), Some(output.to_str().unwrap())).unwrap();
+    let ir = std::fs::read_to_string(&output).unwrap();
+    assert_eq!(
+        ir.starts_with(
+            format!(
+                "; ModuleID = '{}'\nsource_filename = \"{}\"",
+                input.display(),
+                input.display()
+            )
+            .as_str()
+        ),
+        true
+    );
+    assert_eq!(ir.contains("define void @main()"), true);
+    std::fs::remove_file(input).unwrap();
+    std::fs::remove_file(output).unwrap();
+}
+
+#[test]
+fn emit_ir_with_errors() {
+    let input = temp_file("emit_ir_with_errors.elz");
+    let output = temp_file("emit_ir_with_errors.ll");
+    std::fs::write(&input, "module main\nmain(): void { continue; }").unwrap();
+    let result = emit_ir(input.to_str().unwrap(), Some(output.to_str().unwrap()));
+    assert_eq!(result.is_err(), true);
+    assert_eq!(output.exists(), false);
+    std::fs::remove_file(input).unwrap();
+}
+
+#[test]
+fn run_returns_exit_code_of_main() {
+    if !llvm_available(lli()) {
+        return;
+    }
+    let input = temp_file("run_returns_exit_code_of_main.elz");
+    std::fs::write(&input, "module main\nmain(): int = 40 + 2;").unwrap();
+    assert_eq!(run(input.to_str().unwrap()).unwrap(), 42);
+    std::fs::write(&input, "module main\nmain(): void {}").unwrap();
+    assert_eq!(run(input.to_str().unwrap()).unwrap(), 0);
+    std::fs::remove_file(input).unwrap();
+}
+
+#[test]
+fn run_checks_signature_of_main() {
+    let mut reporter = Reporter::new();
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nfoo(): void {}".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    let err = check_main(&module).unwrap_err();
+    assert_eq!(err.to_string(), "no `main` function as entry point");
+    let sources = vec![(
+        "main.elz".to_string(),
+        "module main\nmain(x: int): int = x;".to_string(),
+    )];
+    let module = build(&mut reporter, sources, None).unwrap();
+    let err = check_main(&module).unwrap_err();
+    assert_eq!(
+        err.to_string(),
+        "`main` must have no parameters and return `int`, `i32` or `void`"
+    );
+}
+
+// helpers, must put tests before this line
+/// llvm_available tells if the LLVM tool can run, a test needs it is skipped without LLVM, except
+/// `REQUIRE_LLVM=true` is set, as CI does after installing LLVM
+fn llvm_available(tool: String) -> bool {
+    if std::process::Command::new(&tool)
+        .arg("--version")
+        .output()
+        .is_ok()
+    {
+        return true;
+    }
+    if std::env::var("REQUIRE_LLVM").map_or(false, |v| v == "true") {
+        panic!("`{}` is required but cannot run", tool);
+    }
+    false
+}
+
+/// temp_file is a path under the temporary directory, prefixed by the process id so concurrent
+/// test runs don't share the same file
+fn temp_file(name: &str) -> std::path::PathBuf {
+    std::env::temp_dir().join(format!("elz_{}_{}", std::process::id(), name))
+}
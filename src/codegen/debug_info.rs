@@ -0,0 +1,104 @@
+use crate::lexer::Location;
+use std::collections::HashMap;
+
+/// DebugInfo collects metadata of DWARF line tables, so a debugger can map generated code back to
+/// source lines, nodes are numbered in the order they are added, e.g. `!0`, `!1`
+pub(crate) struct DebugInfo {
+    nodes: Vec<String>,
+    files: HashMap<String, usize>,
+    locations: HashMap<(u32, u32, usize), usize>,
+    compile_unit: usize,
+    subroutine_type: usize,
+    module_flags: Vec<usize>,
+}
+
+impl DebugInfo {
+    pub(crate) fn new(module_name: &str) -> DebugInfo {
+        let mut debug_info = DebugInfo {
+            nodes: vec![],
+            files: HashMap::new(),
+            locations: HashMap::new(),
+            compile_unit: 0,
+            subroutine_type: 0,
+            module_flags: vec![],
+        };
+        let file = debug_info.file(module_name);
+        debug_info.compile_unit = debug_info.add(format!(
+            "distinct !DICompileUnit(language: DW_LANG_C, file: !{}, producer: \"elz\", isOptimized: false, runtimeVersion: 0, emissionKind: LineTablesOnly)",
+            file
+        ));
+        // line tables don't need types, every function shares the same empty signature
+        debug_info.subroutine_type = debug_info.add("!DISubroutineType(types: !{})".to_string());
+        debug_info.module_flags = vec![
+            debug_info.add("!{i32 2, !\"Dwarf Version\", i32 4}".to_string()),
+            debug_info.add("!{i32 2, !\"Debug Info Version\", i32 3}".to_string()),
+        ];
+        debug_info
+    }
+
+    /// subprogram describes a function defined at the location, `name` is the symbol without `@`
+    pub(crate) fn subprogram(&mut self, name: &str, location: &Location) -> usize {
+        let file = self.file(location.file_name());
+        self.add(format!(
+            "distinct !DISubprogram(name: {name}, scope: !{file}, file: !{file}, line: {line}, type: !{typ}, scopeLine: {line}, spFlags: DISPFlagDefinition, unit: !{unit})",
+            name = metadata_string(name.trim_matches('"')),
+            file = file,
+            line = location.line(),
+            typ = self.subroutine_type,
+            unit = self.compile_unit
+        ))
+    }
+
+    /// location is the source position of instructions in the subprogram `scope`, column starts
+    /// from 1 in DWARF
+    pub(crate) fn location(&mut self, location: &Location, scope: usize) -> usize {
+        let key = (location.line(), location.column() + 1, scope);
+        if let Some(id) = self.locations.get(&key) {
+            return *id;
+        }
+        let id = self.add(format!(
+            "!DILocation(line: {}, column: {}, scope: !{})",
+            key.0, key.1, key.2
+        ));
+        self.locations.insert(key, id);
+        id
+    }
+
+    fn file(&mut self, file_name: &str) -> usize {
+        if let Some(id) = self.files.get(file_name) {
+            return *id;
+        }
+        let id = self.add(format!(
+            "!DIFile(filename: {}, directory: \"\")",
+            metadata_string(file_name)
+        ));
+        self.files.insert(file_name.to_string(), id);
+        id
+    }
+
+    fn add(&mut self, node: String) -> usize {
+        self.nodes.push(node);
+        self.nodes.len() - 1
+    }
+
+    /// llvm_represent is named metadata and all nodes, which are put at the end of module
+    pub(crate) fn llvm_represent(&self) -> String {
+        let mut s = String::new();
+        s.push_str(format!("!llvm.dbg.cu = !{{!{}}}\n", self.compile_unit).as_str());
+        let flags: Vec<String> = self
+            .module_flags
+            .iter()
+            .map(|id| format!("!{}", id))
+            .collect();
+        s.push_str(format!("!llvm.module.flags = !{{{}}}\n", flags.join(", ")).as_str());
+        for (id, node) in self.nodes.iter().enumerate() {
+            s.push_str(format!("!{} = {}\n", id, node).as_str());
+        }
+        s
+    }
+}
+
+/// metadata_string quotes the string, `"` and `\` must be written as `\XX` in LLVM
+fn metadata_string(s: &str) -> String {
+    format!("\"{}\"", s.replace('\\', "\\5C").replace('"', "\\22"))
+}
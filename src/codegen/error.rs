@@ -0,0 +1,93 @@
+use crate::ast::Operator;
+use crate::lexer::Location;
+use thiserror::Error;
+
+#[derive(Debug, Error, Clone)]
+#[error("{location} {err}")]
+pub struct CodegenError {
+    location: Location,
+    err: CodegenErrorVariant,
+}
+
+#[derive(Debug, Error, Clone)]
+enum CodegenErrorVariant {
+    #[error("unsupported operator `{}` on non-constant operands", .0)]
+    UnsupportedOperator(Operator),
+    #[error("cannot evaluate constant expression")]
+    NotConstant,
+    #[error("cannot know element type of an empty list")]
+    UnknownElementType,
+    #[error("index {} out of bounds for list of length {}", .index, .len)]
+    IndexOutOfBounds { index: i64, len: usize },
+    #[error("index {} out of bounds, index of list cannot be negative", .0)]
+    NegativeIndex(i64),
+    #[error("pattern doesn't fit the type of matched value")]
+    InvalidPattern,
+    #[error("class `{}` doesn't implement method `{}` of trait `{}`", .class_name, .method_name, .trait_name)]
+    MissingTraitMethod {
+        class_name: String,
+        trait_name: String,
+        method_name: String,
+    },
+}
+
+impl CodegenError {
+    pub(crate) fn location(&self) -> Location {
+        self.location.clone()
+    }
+    pub(crate) fn message(&self) -> String {
+        format!("{}", self.err)
+    }
+
+    pub fn unsupported_operator(location: &Location, op: &Operator) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::UnsupportedOperator(op.clone()),
+        }
+    }
+    pub fn not_constant(location: &Location) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::NotConstant,
+        }
+    }
+    pub fn unknown_element_type(location: &Location) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::UnknownElementType,
+        }
+    }
+    pub fn index_out_of_bounds(location: &Location, index: i64, len: usize) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::IndexOutOfBounds { index, len },
+        }
+    }
+    pub fn negative_index(location: &Location, index: i64) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::NegativeIndex(index),
+        }
+    }
+    pub fn invalid_pattern(location: &Location) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::InvalidPattern,
+        }
+    }
+    pub fn missing_trait_method(
+        location: &Location,
+        class_name: &String,
+        trait_name: &String,
+        method_name: &String,
+    ) -> CodegenError {
+        CodegenError {
+            location: location.clone(),
+            err: CodegenErrorVariant::MissingTraitMethod {
+                class_name: class_name.clone(),
+                trait_name: trait_name.clone(),
+                method_name: method_name.clone(),
+            },
+        }
+    }
+}
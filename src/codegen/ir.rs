@@ -1,39 +1,191 @@
+use super::error::CodegenError;
 use crate::ast;
 use crate::ast::*;
+use crate::constant::{self, Constant};
+use crate::lexer::Location;
 use std::cell::RefCell;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::fmt::Formatter;
 use std::ops::Deref;
 use std::rc::Rc;
 
 pub struct Module {
+    /// name is used as module ID and source filename, e.g. the input file name
+    pub(crate) name: String,
+    /// provenance would put source location of each function and global before it
+    pub(crate) provenance: bool,
+    /// debug_info would emit DWARF line tables for functions
+    pub(crate) debug_info: bool,
     // helpers
+    /// known_functions maps function name to its `Type::Function`
     pub(crate) known_functions: HashMap<String, Type>,
     pub(crate) known_variables: HashMap<String, Type>,
+    /// traits are names of trait types, a value of them is a trait object
+    traits: HashSet<String>,
+    /// string_literals pools the global of each distinct string literal
+    string_literals: HashMap<String, Rc<RefCell<ID>>>,
     // output parts
     pub(crate) functions: HashMap<String, Function>,
     pub(crate) variables: Vec<Variable>,
     pub(crate) types: HashMap<String, Type>,
+    /// errors are reported while codegen keeps going, so one pass can find all of them
+    pub(crate) errors: Vec<CodegenError>,
 }
 
 impl Module {
-    pub(crate) fn new() -> Module {
+    pub(crate) fn new<T: ToString>(name: T) -> Module {
         Module {
+            name: name.to_string(),
+            provenance: false,
+            debug_info: false,
             known_functions: HashMap::new(),
             known_variables: HashMap::new(),
+            traits: HashSet::new(),
+            string_literals: HashMap::new(),
             functions: HashMap::new(),
             variables: vec![],
             types: HashMap::new(),
+            errors: vec![],
         }
     }
     pub(crate) fn remember_function(&mut self, f: &ast::Function) {
-        let ret_type = Type::from_ast(&f.ret_typ, self);
-        self.known_functions.insert(f.name.clone(), ret_type);
+        let typ = Type::function(f.parameters.iter(), &f.ret_typ, self);
+        self.known_functions.insert(f.name.clone(), typ);
+    }
+    /// remember_method remembers a method or static method of class by its symbol name, e.g.
+    /// `"Car::new"`, a method takes `self` as the first parameter
+    pub(crate) fn remember_method(
+        &mut self,
+        class_name: &String,
+        f: &ast::Function,
+        is_static: bool,
+    ) {
+        let mut typ = Type::function(f.parameters.iter(), &f.ret_typ, self);
+        if let (Type::Function { parameters, .. }, false) = (&mut typ, is_static) {
+            parameters.insert(0, self.lookup_type(class_name).clone());
+        }
+        self.known_functions
+            .insert(format!("\"{}::{}\"", class_name, f.name), typ);
     }
     pub(crate) fn remember_variable(&mut self, v: &ast::Variable) {
         self.known_variables
             .insert(v.name.clone(), Type::from_ast(&v.typ, self));
     }
+    /// function finds a function by its source name, e.g. `main` or `"Car::new"`
+    pub fn function(&self, name: &str) -> Option<&Function> {
+        self.functions.get(&format!("@{}", name))
+    }
+    /// set_entry_point wraps `main(): void` or `main(): int` with `i32 @main()`, which returns the
+    /// exit code C runtime expects, `0` for `void` and the value truncated to `i32` for `int`. The
+    /// wrapped function is renamed to `@"elz.main"`, returns false if there is no `main`
+    pub(crate) fn set_entry_point(&mut self) -> bool {
+        let mut main = match self.functions.remove("@main") {
+            Some(main) => main,
+            None => return false,
+        };
+        let wrapped = match main.ret_typ {
+            Type::Void | Type::Int(64) => main.parameters.is_empty(),
+            _ => false,
+        };
+        if !wrapped {
+            self.push_function(main);
+            return true;
+        }
+        let body_name = "@\"elz.main\"".to_string();
+        main.name = body_name.clone();
+        for f in self.functions.values_mut() {
+            for inst in f.body.iter_mut().flat_map(|b| b.instructions.iter_mut()) {
+                if let Instruction::FunctionCall { func_name, .. } = inst {
+                    if func_name == "@main" {
+                        *func_name = body_name.clone();
+                    }
+                }
+            }
+        }
+        let call_id = ID::new();
+        let mut instructions = vec![Instruction::FunctionCall {
+            id: call_id.clone(),
+            func_name: body_name,
+            ret_type: main.ret_typ.clone().into(),
+            args_expr: vec![],
+        }];
+        let exit_code = match main.ret_typ {
+            Type::Void => Expr::I32(0),
+            _ => {
+                let trunc_id = ID::new();
+                instructions.push(Instruction::Convert {
+                    id: trunc_id.clone(),
+                    op_name: "trunc".to_string(),
+                    from: Expr::local_id(main.ret_typ.clone(), call_id),
+                    target_type: Type::Int(32),
+                });
+                Expr::local_id(Type::Int(32), trunc_id)
+            }
+        };
+        instructions.push(Instruction::Return(Some(exit_code)));
+        let mut counter = 1;
+        for inst in &mut instructions {
+            if inst.set_id(counter) {
+                counter += 1;
+            }
+        }
+        let entry = Function {
+            name: "@main".to_string(),
+            parameters: vec![],
+            ret_typ: Type::Int(32),
+            body: Some(Body {
+                instructions,
+                scopes: vec![],
+                ret_typ: Type::Int(32),
+                loops: vec![],
+                allocas: vec![],
+                list_lengths: vec![],
+                locations: main.location.iter().map(|l| (0, l.clone())).collect(),
+                entry: Label::new(ID::new()),
+            }),
+            location: main.location.clone(),
+            variadic: false,
+        };
+        self.push_function(main);
+        self.push_function(entry);
+        true
+    }
+    /// declare_intrinsic declares an LLVM intrinsic once and returns its name, e.g. `@llvm.pow.f64`
+    fn declare_intrinsic(&mut self, name: &str, parameters: Vec<Type>, ret_typ: Type) -> String {
+        let name = format!("@{}", name);
+        if !self.functions.contains_key(&name) {
+            let parameters = parameters
+                .into_iter()
+                .enumerate()
+                .map(|(i, typ)| (format!("{}", i), typ))
+                .collect();
+            self.push_function(Function {
+                name: name.clone(),
+                parameters,
+                ret_typ,
+                body: None,
+                location: None,
+                variadic: false,
+            });
+        }
+        name
+    }
+    /// declare_printf declares C `printf` once and returns the callee of a call, a call to a
+    /// variadic function must spell out its signature, e.g. `(i8*, ...) @printf`
+    fn declare_printf(&mut self) -> String {
+        let name = "@printf".to_string();
+        if !self.functions.contains_key(&name) {
+            self.push_function(Function {
+                name: name.clone(),
+                parameters: vec![("format".to_string(), Type::Pointer(Type::Int(8).into()))],
+                ret_typ: Type::Int(32),
+                body: None,
+                location: None,
+                variadic: true,
+            });
+        }
+        format!("(i8*, ...) {}", name)
+    }
     pub(crate) fn push_function(&mut self, f: Function) {
         self.functions.insert(f.name.clone(), f);
     }
@@ -60,11 +212,136 @@ impl Module {
         };
         self.types.insert(type_name.clone(), typ);
     }
+    /// push_trait defines the type of trait object and its vtable, a trait object points to the
+    /// implementor value and the vtable of implementor, e.g. `%Show = type { i8*, %"Show.vtable"* }`
+    pub(crate) fn push_trait(&mut self, t: &ast::Trait) {
+        let vtable_name = vtable_type_name(&t.name);
+        let methods = t
+            .members
+            .iter()
+            .filter_map(|member| match member {
+                // `self` inserted by parser is the implementor, so it's unknown here
+                TraitMember::Method(method) => {
+                    let mut typ =
+                        Type::function(method.parameters.iter().skip(1), &method.ret_typ, self);
+                    if let Type::Function { parameters, .. } = &mut typ {
+                        parameters.insert(0, Type::Pointer(Type::Int(8).into()));
+                    }
+                    Some(Field {
+                        name: method.name.clone(),
+                        typ: Type::Pointer(typ.into()).into(),
+                    })
+                }
+                TraitMember::Field(_) => None,
+            })
+            .collect();
+        self.types.insert(
+            vtable_name.clone(),
+            Type::Struct {
+                name: vtable_name.clone(),
+                fields: methods,
+            },
+        );
+        let fields = vec![
+            Field {
+                name: "value".to_string(),
+                typ: Type::Pointer(Type::Int(8).into()).into(),
+            },
+            Field {
+                name: "vtable".to_string(),
+                typ: Type::Pointer(Type::Named(vtable_name).into()).into(),
+            },
+        ];
+        self.types.insert(
+            t.name.clone(),
+            Type::Struct {
+                name: t.name.clone(),
+                fields,
+            },
+        );
+        self.traits.insert(t.name.clone());
+    }
+    fn is_trait(&self, typ: &Type) -> bool {
+        match typ {
+            Type::Struct { name, .. } => self.traits.contains(name),
+            _ => false,
+        }
+    }
+    /// vtable returns the global vtable of class for trait, e.g. `@"Circle as Shape"`, every
+    /// method of class is casted to take `i8*` as `self`, so they have the same type for all
+    /// implementors
+    fn vtable(
+        &mut self,
+        class_name: &String,
+        trait_name: &String,
+        location: &Location,
+    ) -> Result<Expr, CodegenError> {
+        let name = format!("@\"{} as {}\"", class_name, trait_name);
+        let vtable_type = self.lookup_type(&vtable_type_name(trait_name)).clone();
+        let typ = Type::Pointer(vtable_type.element_type());
+        if !self
+            .variables
+            .iter()
+            .any(|v| v.name == GlobalName::String(name.clone()))
+        {
+            let methods = match &vtable_type {
+                Type::Struct { fields, .. } => fields
+                    .iter()
+                    .map(|field| {
+                        let method_name = format!("\"{}::{}\"", class_name, field.name);
+                        let method_type = match self.known_functions.get(&method_name) {
+                            Some(method_type) => method_type.clone(),
+                            None => {
+                                return Err(CodegenError::missing_trait_method(
+                                    location,
+                                    class_name,
+                                    trait_name,
+                                    &field.name,
+                                ))
+                            }
+                        };
+                        let method = Expr::Global(
+                            Type::Pointer(method_type.into()),
+                            format!("@{}", method_name),
+                        );
+                        Ok(Expr::BitCast(method.into(), field.typ.deref().clone()))
+                    })
+                    .collect::<Result<_, _>>()?,
+                _ => unreachable!(),
+            };
+            self.push_variable(Variable {
+                name: GlobalName::String(name.clone()),
+                expr: Expr::Struct(vtable_type.element_type().deref().clone(), methods),
+                location: None,
+            });
+        }
+        Ok(Expr::Global(typ, name))
+    }
+    /// string_literal returns the global which stores the string literal, identical literals
+    /// share the same global
+    fn string_literal(&mut self, string_literal: &String) -> Rc<RefCell<ID>> {
+        if let Some(id) = self.string_literals.get(string_literal) {
+            return id.clone();
+        }
+        let id = ID::new();
+        id.borrow_mut().set_id(self.string_literals.len() as u64);
+        self.push_variable(Variable::from_id(
+            id.clone(),
+            Expr::CString(string_literal.clone()),
+        ));
+        self.string_literals
+            .insert(string_literal.clone(), id.clone());
+        id
+    }
     fn lookup_type(&self, type_name: &String) -> &Type {
         self.types.get(type_name).unwrap()
     }
 }
 
+fn vtable_type_name(trait_name: &String) -> String {
+    format!("\"{}.vtable\"", trait_name)
+}
+
 #[derive(Debug, Clone, PartialEq)]
 pub(crate) struct ID {
     value: u64,
@@ -108,32 +385,67 @@ pub(crate) enum Instruction {
         if_false: Rc<Label>,
     },
     Goto(Rc<Label>),
+    /// Switch jumps to the label of the case equals to `value`, or `default` if none of them
+    Switch {
+        value: Expr,
+        default: Rc<Label>,
+        cases: Vec<(Expr, Rc<Label>)>,
+    },
     GEP {
         id: Rc<RefCell<ID>>,
         load_from: Expr,
         indices: Vec<u64>,
     },
+    /// ElementPtr is the address of `load_from[index]`, the index is only known at runtime
+    ElementPtr {
+        id: Rc<RefCell<ID>>,
+        load_from: Expr,
+        index: Expr,
+    },
     FunctionCall {
         id: Rc<RefCell<ID>>,
         func_name: String,
         ret_type: Box<Type>,
         args_expr: Vec<Expr>,
     },
+    /// IndirectCall calls a function pointer, e.g. a method in vtable
+    IndirectCall {
+        id: Rc<RefCell<ID>>,
+        callee: Expr,
+        ret_type: Box<Type>,
+        args_expr: Vec<Expr>,
+    },
     BinaryOperation {
         id: Rc<RefCell<ID>>,
         op_name: String,
         lhs: Expr,
         rhs: Expr,
     },
+    FNeg {
+        id: Rc<RefCell<ID>>,
+        operand: Expr,
+    },
     Malloca {
         id: Rc<RefCell<ID>>,
         typ: Type,
     },
     BitCast {
         id: Rc<RefCell<ID>>,
-        from_id: Rc<RefCell<ID>>,
+        from: Expr,
         target_type: Type,
     },
+    /// Convert converts a number to another number type, e.g. `sext i32 %x to i64`
+    Convert {
+        id: Rc<RefCell<ID>>,
+        op_name: String,
+        from: Expr,
+        target_type: Type,
+    },
+    /// Alloca reserves stack memory for a mutable local, e.g. `%1 = alloca i64`
+    Alloca {
+        id: Rc<RefCell<ID>>,
+        typ: Type,
+    },
     Load {
         id: Rc<RefCell<ID>>,
         load_from: Expr,
@@ -142,13 +454,30 @@ pub(crate) enum Instruction {
         source: Expr,
         destination: Rc<RefCell<ID>>,
     },
+    /// InsertValue is a copy of tuple `aggregate` with element at `index` replaced by `value`
+    InsertValue {
+        id: Rc<RefCell<ID>>,
+        aggregate: Expr,
+        value: Expr,
+        index: u64,
+    },
+    ExtractValue {
+        id: Rc<RefCell<ID>>,
+        aggregate: Expr,
+        index: u64,
+    },
+    /// Phi picks the value from the block jumped from
+    Phi {
+        id: Rc<RefCell<ID>>,
+        incoming: Vec<(Expr, Rc<Label>)>,
+    },
 }
 
 impl Instruction {
     pub(crate) fn is_terminator(&self) -> bool {
         use Instruction::*;
         match self {
-            Return(..) | Branch { .. } | Goto(..) => true,
+            Return(..) | Branch { .. } | Switch { .. } | Goto(..) => true,
             _ => false,
         }
     }
@@ -157,12 +486,26 @@ impl Instruction {
         use Instruction::*;
         match self {
             Label(label) => label.id.borrow_mut().set_id(value),
+            // a call returns `void` has no value to be named
+            FunctionCall { ret_type, .. } | IndirectCall { ret_type, .. }
+                if **ret_type == Type::Void =>
+            {
+                false
+            }
             Load { id, .. }
+            | Alloca { id, .. }
             | Malloca { id, .. }
             | BitCast { id, .. }
+            | Convert { id, .. }
             | GEP { id, .. }
+            | ElementPtr { id, .. }
             | FunctionCall { id, .. }
-            | BinaryOperation { id, .. } => id.borrow_mut().set_id(value),
+            | IndirectCall { id, .. }
+            | BinaryOperation { id, .. }
+            | InsertValue { id, .. }
+            | ExtractValue { id, .. }
+            | Phi { id, .. }
+            | FNeg { id, .. } => id.borrow_mut().set_id(value),
             _ => false,
         }
     }
@@ -170,7 +513,17 @@ impl Instruction {
 
 #[derive(Debug, Clone, PartialEq)]
 pub(crate) enum LocalVariable {
-    Name { typ: Type, name: String },
+    Name {
+        typ: Type,
+        name: String,
+    },
+    /// Value is a local bound to a computed value, e.g. `x` of `(x, y): (int, int) = pair();`
+    Value(Expr),
+    /// Slot is a mutable local stored in stack, reading it loads the latest assigned value
+    Slot {
+        typ: Type,
+        id: Rc<RefCell<ID>>,
+    },
 }
 
 impl LocalVariable {
@@ -179,15 +532,43 @@ impl LocalVariable {
     }
 }
 
+/// LoopLabels are targets of jumps in a loop body
+#[derive(Debug, Clone, PartialEq)]
+struct LoopLabels {
+    head: Rc<Label>,
+    end: Rc<Label>,
+    // true if any `break` jumps to `end`
+    reach_end: bool,
+}
+
 #[derive(Debug, Clone, PartialEq)]
 pub(crate) struct Body {
     pub(crate) instructions: Vec<Instruction>,
-    // local variables(including parameters)
-    variables: HashMap<String, LocalVariable>,
+    // local variables(including parameters) by blocks, the innermost block is the last one
+    scopes: Vec<HashMap<String, LocalVariable>>,
+    ret_typ: Type,
+    // enclosing loops, the innermost is the last one
+    loops: Vec<LoopLabels>,
+    // allocas are moved to the entry block at the end, so a mutable local in loop doesn't grow
+    // stack in each iteration
+    allocas: Vec<Instruction>,
+    // lengths of lists allocated from list literals, by ID of the list
+    list_lengths: Vec<(Rc<RefCell<ID>>, usize)>,
+    /// locations are where statements start, the index of the first instruction of a statement
+    /// and its location
+    pub(crate) locations: Vec<(usize, Location)>,
+    // label of the entry block, which has no label instruction and is numbered `%0` before all
+    // local values
+    entry: Rc<Label>,
 }
 
 impl Body {
-    fn from_ast(b: &ast::Body, module: &mut Module, parameters: &Vec<Parameter>) -> Body {
+    fn from_ast(
+        b: &ast::Body,
+        module: &mut Module,
+        parameters: &Vec<Parameter>,
+        ret_typ: &Type,
+    ) -> Body {
         let mut variables = HashMap::new();
 
         for p in parameters {
@@ -199,16 +580,31 @@ impl Body {
 
         let mut body = Body {
             instructions: vec![],
-            variables,
+            scopes: vec![variables],
+            ret_typ: ret_typ.clone(),
+            loops: vec![],
+            allocas: vec![],
+            list_lengths: vec![],
+            locations: vec![],
+            entry: Label::new(ID::new()),
         };
         match b {
             ast::Body::Expr(e) => {
-                let e = body.expr_from_ast(e, module);
+                body.locations.push((0, e.location.clone()));
+                let location = e.location.clone();
+                let e = body.expr_in_context(e, ret_typ, module);
+                let e = body.upcast(e, ret_typ, &location, module);
                 body.instructions.push(Instruction::Return(Some(e)));
             }
             ast::Body::Block(b) => body.generate_instructions(&b.statements, module),
         };
+        let allocas: Vec<_> = body.allocas.drain(..).collect();
+        for (start, _) in &mut body.locations {
+            *start += allocas.len();
+        }
+        body.instructions.splice(0..0, allocas);
         // update local identifier value
+        body.entry.id.borrow_mut().set_id(0);
         let mut counter = 1;
         for inst in &mut body.instructions {
             if inst.set_id(counter) {
@@ -218,18 +614,55 @@ impl Body {
         body
     }
 
+    /// location_of is the location of statement generates the instruction at `index`
+    pub(crate) fn location_of(&self, index: usize) -> Option<&Location> {
+        self.locations
+            .iter()
+            .rev()
+            .find(|(start, _)| *start <= index)
+            .map(|(_, location)| location)
+    }
+    /// lookup_variable finds the variable from the innermost block, so an inner variable shadows
+    /// the outer one with the same name
     fn lookup_variable(&self, name: &String) -> Option<&LocalVariable> {
-        self.variables.get(name)
+        self.scopes.iter().rev().find_map(|scope| scope.get(name))
+    }
+    fn bind(&mut self, name: &String, local_var: LocalVariable) {
+        self.scopes
+            .last_mut()
+            .unwrap()
+            .insert(name.clone(), local_var);
+    }
+    fn is_field_of_self(&self, name: &String) -> bool {
+        match self.lookup_variable(&"self".to_string()) {
+            Some(LocalVariable::Name {
+                typ: Type::Struct { fields, .. },
+                ..
+            }) => fields.iter().any(|field| &field.name == name),
+            _ => false,
+        }
     }
 
     pub(crate) fn generate_instructions(&mut self, stmts: &Vec<Statement>, module: &mut Module) {
+        // variables defined in the block are dropped once the block ends
+        self.scopes.push(HashMap::new());
         for stmt in stmts {
             use ast::StatementVariant::*;
+            self.locations
+                .push((self.instructions.len(), stmt.location.clone()));
             match &stmt.value {
                 Return(e) => {
                     let inst = match e {
                         None => Instruction::Return(None),
-                        Some(ex) => Instruction::Return(Some(self.expr_from_ast(ex, module))),
+                        Some(ex) => {
+                            let e = self.expr_in_context(ex, &self.ret_typ.clone(), module);
+                            let e = self.upcast(e, &self.ret_typ.clone(), &ex.location, module);
+                            match e.type_() {
+                                // e.g. `{ println("hello") }`, the call is already evaluated
+                                Type::Void => Instruction::Return(None),
+                                _ => Instruction::Return(Some(e)),
+                            }
+                        }
                     };
                     self.instructions.push(inst)
                 }
@@ -241,6 +674,7 @@ impl Body {
                     else_block,
                 } => {
                     let leave_label = Label::new(ID::new());
+                    let mut reach_leave = false;
                     for (cond, then_block) in clauses {
                         let if_then_label = Label::new(ID::new());
                         let else_then_label = Label::new(ID::new());
@@ -256,6 +690,7 @@ impl Body {
                         self.generate_instructions(&then_block.statements, module);
                         if !self.end_with_terminator() {
                             self.goto(&leave_label);
+                            reach_leave = true;
                         }
                         // else then
                         self.instructions
@@ -264,17 +699,130 @@ impl Body {
                     self.generate_instructions(&else_block.statements, module);
                     if !self.end_with_terminator() {
                         self.goto(&leave_label);
+                        reach_leave = true;
+                    }
+                    // when every branch returns, the leave block would be an empty block without terminator
+                    if reach_leave {
+                        self.instructions
+                            .push(Instruction::Label(leave_label.clone()));
                     }
-                    self.instructions
-                        .push(Instruction::Label(leave_label.clone()));
                 }
                 Variable(v) => {
-                    self.expr_from_ast(&v.expr, module);
+                    let typ = Type::from_ast(&v.typ, module);
+                    let e = self.expr_in_context(&v.expr, &typ, module);
+                    let e = self.upcast(e, &typ, &v.expr.location, module);
+                    if v.mutable {
+                        let id = ID::new();
+                        self.allocas.push(Instruction::Alloca {
+                            id: id.clone(),
+                            typ: typ.clone(),
+                        });
+                        self.instructions.push(Instruction::Store {
+                            source: e,
+                            destination: id.clone(),
+                        });
+                        self.bind(&v.name, LocalVariable::Slot { typ, id });
+                    } else {
+                        self.bind(&v.name, LocalVariable::Value(e));
+                    }
+                }
+                Assign(name, expr) => {
+                    let (typ, id) = match self.lookup_variable(name) {
+                        Some(LocalVariable::Slot { typ, id }) => (typ.clone(), id.clone()),
+                        _ => unreachable!("assign to immutable variable `{}`, semantic module must have a bug there!", name),
+                    };
+                    let e = self.expr_in_context(expr, &typ, module);
+                    let e = self.upcast(e, &typ, &expr.location, module);
+                    self.instructions.push(Instruction::Store {
+                        source: e,
+                        destination: id,
+                    });
+                }
+                Destructure(names, _, expr) => {
+                    let tuple = self.expr_from_ast(expr, module);
+                    let element_types = match tuple.type_() {
+                        Type::Tuple(types) => types,
+                        t => unreachable!("destructure non-tuple type `{:?}`", t),
+                    };
+                    for (i, (name, typ)) in names.iter().zip(element_types).enumerate() {
+                        let id = ID::new();
+                        self.instructions.push(Instruction::ExtractValue {
+                            id: id.clone(),
+                            aggregate: tuple.clone(),
+                            index: i as u64,
+                        });
+                        self.bind(name, LocalVariable::Value(Expr::local_id(typ, id)));
+                    }
+                }
+                Loop(block) => {
+                    let head = Label::new(ID::new());
+                    self.goto(&head);
+                    self.instructions.push(Instruction::Label(head.clone()));
+                    self.loops.push(LoopLabels {
+                        head: head.clone(),
+                        end: Label::new(ID::new()),
+                        reach_end: false,
+                    });
+                    self.generate_instructions(&block.statements, module);
+                    if !self.end_with_terminator() {
+                        self.goto(&head);
+                    }
+                    let labels = self.loops.pop().unwrap();
+                    // without `break` the loop never ends, nothing follows it
+                    if labels.reach_end {
+                        self.instructions.push(Instruction::Label(labels.end));
+                    }
+                }
+                While(condition, block) => {
+                    let head = Label::new(ID::new());
+                    let body = Label::new(ID::new());
+                    let end = Label::new(ID::new());
+                    self.goto(&head);
+                    self.instructions.push(Instruction::Label(head.clone()));
+                    let inst = Instruction::Branch {
+                        cond: self.expr_from_ast(condition, module),
+                        if_true: body.clone(),
+                        if_false: end.clone(),
+                    };
+                    self.instructions.push(inst);
+                    self.instructions.push(Instruction::Label(body));
+                    self.loops.push(LoopLabels {
+                        head: head.clone(),
+                        end: end.clone(),
+                        reach_end: true,
+                    });
+                    self.generate_instructions(&block.statements, module);
+                    if !self.end_with_terminator() {
+                        self.goto(&head);
+                    }
+                    self.loops.pop();
+                    self.instructions.push(Instruction::Label(end));
+                }
+                Break => {
+                    let labels = self
+                        .loops
+                        .last_mut()
+                        .expect("`break` outside of loop, semantic module must have a bug there!");
+                    labels.reach_end = true;
+                    let end = labels.end.clone();
+                    self.goto(&end);
+                }
+                Continue => {
+                    let head = self
+                        .loops
+                        .last()
+                        .expect(
+                            "`continue` outside of loop, semantic module must have a bug there!",
+                        )
+                        .head
+                        .clone();
+                    self.goto(&head);
                 }
             }
         }
+        self.scopes.pop();
     }
-    fn end_with_terminator(&self) -> bool {
+    pub(crate) fn end_with_terminator(&self) -> bool {
         match self.instructions.last() {
             None => false,
             Some(inst) => inst.is_terminator(),
@@ -286,11 +834,14 @@ impl Body {
 }
 
 #[derive(Debug, Clone, PartialEq)]
-pub(crate) struct Function {
+pub struct Function {
     pub(crate) name: String,
     pub(crate) parameters: Vec<(String, Type)>,
     pub(crate) ret_typ: Type,
     pub(crate) body: Option<Body>,
+    pub(crate) location: Option<Location>,
+    /// variadic takes arguments after `parameters`, e.g. `printf`
+    pub(crate) variadic: bool,
 }
 
 impl Function {
@@ -299,8 +850,9 @@ impl Function {
         class_name: Option<String>,
         module: &mut Module,
     ) -> Function {
+        let ret_typ = Type::from_ast(&f.ret_typ, module);
         let body = match &f.body {
-            Some(b) => Some(Body::from_ast(b, module, &f.parameters)),
+            Some(b) => Some(Body::from_ast(b, module, &f.parameters, &ret_typ)),
             None => None,
         };
         let function_name = match class_name {
@@ -309,14 +861,16 @@ impl Function {
         };
         Function::new(
             function_name,
+            &f.location,
             &f.parameters,
-            Type::from_ast(&f.ret_typ, module),
+            ret_typ,
             body,
             module,
         )
     }
     fn new(
         name: String,
+        location: &Location,
         parsed_params: &Vec<Parameter>,
         ret_typ: Type,
         body: Option<Body>,
@@ -332,6 +886,8 @@ impl Function {
             parameters,
             ret_typ,
             body,
+            location: Some(location.clone()),
+            variadic: false,
         }
     }
 }
@@ -340,6 +896,7 @@ impl Function {
 pub(crate) struct Variable {
     pub(crate) name: GlobalName,
     pub(crate) expr: Expr,
+    pub(crate) location: Option<Location>,
 }
 
 #[derive(Debug, Clone, PartialEq)]
@@ -349,29 +906,100 @@ pub(crate) enum GlobalName {
 }
 
 impl Variable {
-    pub(crate) fn new(name: String, expr: Expr) -> Variable {
+    pub(crate) fn new(name: String, location: &Location, expr: Expr) -> Variable {
         Variable {
             name: GlobalName::String(format!("@{}", name)),
             expr,
+            location: Some(location.clone()),
         }
     }
+    /// list_from_ast puts the elements of a constant list into `@"<name>.data"`, and the global
+    /// `@<name>` points to its first element, e.g. `xs: List[int] = [0; 4];`
+    pub(crate) fn list_from_ast(
+        v: &ast::Variable,
+        element_type: &Type,
+        module: &mut Module,
+    ) -> Result<Variable, CodegenError> {
+        let elements = match &v.expr.value {
+            ExprVariant::List(elements) => elements
+                .iter()
+                .map(|element| Ok(Expr::from_ast(element, module)?.coerce(element_type)))
+                .collect::<Result<Vec<Expr>, CodegenError>>()?,
+            ExprVariant::ListRepeat(element, count) => {
+                let element = Expr::from_ast(element, module)?.coerce(element_type);
+                // semantic checker limits the count of a non-zero element
+                if element.to_constant().map_or(false, |c| c.is_zero()) {
+                    let typ = Type::Array {
+                        len: *count,
+                        element_type: element_type.clone().into(),
+                    };
+                    return Ok(Variable::list_of_data(
+                        v,
+                        element_type,
+                        Expr::Zero(typ),
+                        module,
+                    ));
+                }
+                vec![element; *count]
+            }
+            _ => return Err(CodegenError::not_constant(&v.expr.location)),
+        };
+        let data = Expr::Array(element_type.clone(), elements);
+        Ok(Variable::list_of_data(v, element_type, data, module))
+    }
+    /// list_of_data defines `data` as `@"<name>.data"`, and returns `@<name>` points to it
+    fn list_of_data(
+        v: &ast::Variable,
+        element_type: &Type,
+        data: Expr,
+        module: &mut Module,
+    ) -> Variable {
+        let data = Variable::new(format!("\"{}.data\"", v.name), &v.location, data);
+        let data_ref = Expr::Global(
+            Type::Pointer(data.expr.type_().into()),
+            format!("@\"{}.data\"", v.name),
+        );
+        module.push_variable(data);
+        Variable::new(
+            v.name.clone(),
+            &v.location,
+            Expr::BitCast(data_ref.into(), Type::Pointer(element_type.clone().into())),
+        )
+    }
     pub(crate) fn from_id(id: Rc<RefCell<ID>>, expr: Expr) -> Variable {
         Variable {
             name: GlobalName::ID(id),
             expr,
+            location: None,
         }
     }
 }
 
+/// POINTER_SIZE is the size in bits of a pointer on the target
+pub(crate) const POINTER_SIZE: usize = 64;
+
 #[derive(Debug, Clone, PartialEq)]
 pub(crate) enum Type {
     Void,
     Int(usize),
     Float(usize),
     Pointer(Rc<Type>),
-    Array { len: usize, element_type: Rc<Type> },
-    Struct { name: String, fields: Vec<Field> },
+    Array {
+        len: usize,
+        element_type: Rc<Type>,
+    },
+    Struct {
+        name: String,
+        fields: Vec<Field>,
+    },
     Named(String),
+    /// Function is only used as the element of pointer, e.g. `i64 (i8*)*`
+    Function {
+        ret_type: Rc<Type>,
+        parameters: Vec<Type>,
+    },
+    /// Tuple is an anonymous struct passed by value, e.g. `{ i64, i64 }`
+    Tuple(Vec<Type>),
 }
 
 #[derive(Debug, Clone, PartialEq)]
@@ -383,16 +1011,52 @@ pub(crate) struct Field {
 impl Type {
     pub(crate) fn from_ast(t: &ast::ParsedType, module: &Module) -> Type {
         use Type::*;
+        if let ast::ParsedType::Tuple(element_types) = t {
+            return Tuple(
+                element_types
+                    .iter()
+                    .map(|t| Type::from_ast(t, module))
+                    .collect(),
+            );
+        }
         match t.name().as_str() {
             "void" => Void,
             "int" => Int(64),
+            "i8" => Int(8),
+            "i16" => Int(16),
+            "i32" => Int(32),
             "f64" => Float(64),
+            "f32" => Float(32),
             "bool" => Int(1),
+            // a char is an unicode scalar value
+            "char" => Int(32),
             "_c_string" => Pointer(Int(8).into()),
+            // a list is a pointer to its first element
+            "List" => match t.generics().first() {
+                Some(element_type) => Pointer(Type::from_ast(element_type, module).into()),
+                None => unreachable!("`List` without element type"),
+            },
             name => module.lookup_type(&name.to_string()).clone(),
         }
     }
+    fn function<'a, I: Iterator<Item = &'a Parameter>>(
+        parameters: I,
+        ret_typ: &ast::ParsedType,
+        module: &Module,
+    ) -> Type {
+        Type::Function {
+            ret_type: Type::from_ast(ret_typ, module).into(),
+            parameters: parameters.map(|p| Type::from_ast(&p.typ, module)).collect(),
+        }
+    }
 
+    /// struct_name is the name of a class or trait type
+    fn struct_name(&self) -> String {
+        match self {
+            Type::Struct { name, .. } | Type::Named(name) => name.clone(),
+            _ => unreachable!("`{:?}` is not a struct", self),
+        }
+    }
     pub(crate) fn element_type(&self) -> Rc<Type> {
         use Type::*;
         match self {
@@ -402,53 +1066,88 @@ impl Type {
         }
     }
 
+    /// size is the size in bits of a value, a class value is a pointer to its struct
     pub(crate) fn size(&self) -> usize {
         use Type::*;
         match self {
             Int(size) | Float(size) => *size,
-            Pointer(..) => 64,
+            Pointer(..) | Struct { .. } | Named(..) => POINTER_SIZE,
             Array { len, element_type } => len * element_type.size(),
-            Struct { fields, .. } => {
-                let mut size = 0;
-                for field in fields {
-                    size += field.typ.size();
-                }
-                size
+            Tuple(types) => layout_size(types.iter()),
+            Void => 0,
+            Function { .. } => unreachable!("function has no size, only pointer to it has"),
+        }
+    }
+    /// align is the alignment in bits of a value, e.g. `i1` is aligned to a byte
+    pub(crate) fn align(&self) -> usize {
+        use Type::*;
+        match self {
+            Int(size) | Float(size) => size.next_power_of_two().max(8),
+            Pointer(..) | Struct { .. } | Named(..) => POINTER_SIZE,
+            Array { element_type, .. } => element_type.align(),
+            Tuple(types) => types.iter().map(|t| t.align()).max().unwrap_or(8),
+            Void => 8,
+            Function { .. } => unreachable!("function has no alignment, only pointer to it has"),
+        }
+    }
+    /// struct_size is the size in bits to allocate an instance of a class, each field starts at
+    /// the multiple of its alignment, and the whole struct is padded to the largest alignment,
+    /// e.g. `{ i8, i32 }` is 64 bits rather than 40
+    pub(crate) fn struct_size(&self) -> usize {
+        match self {
+            Type::Struct { fields, .. } => {
+                layout_size(fields.iter().map(|field| field.typ.deref()))
             }
-            _ => 0,
+            _ => self.size(),
         }
     }
 }
 
+/// layout_size is the size in bits of a struct with fields in the given types
+fn layout_size<'a, I: Iterator<Item = &'a Type>>(types: I) -> usize {
+    let mut size = 0;
+    let mut struct_align = 8;
+    for typ in types {
+        let align = typ.align();
+        size = round_up(size, align) + round_up(typ.size(), align);
+        struct_align = struct_align.max(align);
+    }
+    round_up(size, struct_align)
+}
+
+fn round_up(size: usize, align: usize) -> usize {
+    (size + align - 1) / align * align
+}
+
 impl Body {
+    /// expr_in_context is expr_from_ast with an expected type, an integer constant would be a
+    /// float when a float is expected, e.g. `return -(2 * 3);` in a function returns `f64`
+    fn expr_in_context(&mut self, expr: &ast::Expr, typ: &Type, module: &mut Module) -> Expr {
+        match (typ, &expr.value) {
+            // `[]` has no element to tell its type, the context does, e.g. `xs: List[int] = [];`
+            (Type::Pointer(element_type), ExprVariant::List(elements)) if elements.is_empty() => {
+                return self.alloc_list(element_type.as_ref().clone(), 0);
+            }
+            (typ, ExprVariant::Match(e, arms)) => return self.switch(e, arms, Some(typ), module),
+            _ => (),
+        }
+        match (typ, Expr::from_ast(expr, module)) {
+            (Type::Float(..), Ok(e @ Expr::I64(..))) => e.coerce(typ),
+            _ => self.expr_from_ast(expr, module).coerce(typ),
+        }
+    }
     fn expr_from_ast(&mut self, expr: &ast::Expr, module: &mut Module) -> Expr {
         use ast::ExprVariant::*;
         match &expr.value {
             String(string_literal) => {
-                let str_literal_id = ID::new();
-                module.push_variable(Variable::from_id(
-                    str_literal_id.clone(),
-                    Expr::CString(string_literal.clone()),
-                ));
-                let str_load_id = ID::new();
-                let array_type = Type::Array {
-                    len: string_literal.len(),
-                    element_type: Type::Int(8).into(),
-                };
-                let inst = Instruction::GEP {
-                    id: str_load_id.clone(),
-                    load_from: Expr::global_id(Type::Pointer(array_type.into()), str_literal_id),
-                    indices: vec![0, 0],
-                };
-                self.instructions.push(inst);
-                let ptr_to_str = Expr::local_id(Type::Pointer(Type::Int(8).into()), str_load_id);
+                let ptr_to_str = self.c_string(string_literal, module);
                 let id = ID::new();
                 let ret_type = module.lookup_type(&"string".to_string());
                 let inst = Instruction::FunctionCall {
                     id: id.clone(),
                     func_name: format!("@\"string::new\""),
                     ret_type: ret_type.clone().into(),
-                    args_expr: vec![ptr_to_str],
+                    args_expr: vec![ptr_to_str, Expr::I64(string_literal.len() as i64)],
                 };
                 self.instructions.push(inst);
                 Expr::local_id(ret_type.clone(), id)
@@ -464,7 +1163,7 @@ impl Body {
                 let bitcast_id = ID::new();
                 let inst = Instruction::BitCast {
                     id: bitcast_id.clone(),
-                    from_id: alloca_id,
+                    from: Expr::local_id(Type::Pointer(Type::Int(8).into()), alloca_id),
                     target_type: class_type.clone(),
                 };
                 self.instructions.push(inst);
@@ -491,7 +1190,8 @@ impl Body {
                         )
                         .as_str(),
                     );
-                    let expr = self.expr_from_ast(init_value, module);
+                    let expr = self.expr_in_context(init_value, &field.typ, module);
+                    let expr = self.upcast(expr, &field.typ, &init_value.location, module);
                     let inst = Instruction::Store {
                         source: expr,
                         destination: gep_id,
@@ -501,6 +1201,104 @@ impl Body {
 
                 Expr::local_id(class_type, bitcast_id)
             }
+            // `[1, 2, 3]` allocates `[3 x i64]` and stores each element
+            List(elements) => {
+                let elements: Vec<Expr> = elements
+                    .iter()
+                    .map(|element| self.expr_from_ast(element, module))
+                    .collect();
+                let element_type = match elements.first() {
+                    Some(first) => first.type_(),
+                    None => {
+                        module
+                            .errors
+                            .push(CodegenError::unknown_element_type(&expr.location));
+                        return Expr::Undef(Type::Pointer(Type::Int(8).into()));
+                    }
+                };
+                let list = self.alloc_list(element_type, elements.len());
+                for (i, element) in elements.into_iter().enumerate() {
+                    let gep_id = ID::new();
+                    self.instructions.push(Instruction::GEP {
+                        id: gep_id.clone(),
+                        load_from: list.clone(),
+                        indices: vec![i as u64],
+                    });
+                    self.instructions.push(Instruction::Store {
+                        source: element,
+                        destination: gep_id,
+                    });
+                }
+                list
+            }
+            // `[0; 4]` evaluates the element once, and stores it in a loop
+            ListRepeat(element, count) => {
+                let element = self.expr_from_ast(element, module);
+                let list = self.alloc_list(element.type_(), *count);
+                self.fill(list.clone(), element, *count);
+                list
+            }
+            // `(1, 2)` inserts each element into an `undef` tuple
+            Tuple(elements) => {
+                let elements: Vec<Expr> = elements
+                    .iter()
+                    .map(|element| self.expr_from_ast(element, module))
+                    .collect();
+                let typ = Type::Tuple(elements.iter().map(|e| e.type_()).collect());
+                let mut tuple = Expr::Undef(typ.clone());
+                for (i, element) in elements.into_iter().enumerate() {
+                    let id = ID::new();
+                    self.instructions.push(Instruction::InsertValue {
+                        id: id.clone(),
+                        aggregate: tuple,
+                        value: element,
+                        index: i as u64,
+                    });
+                    tuple = Expr::local_id(typ.clone(), id);
+                }
+                tuple
+            }
+            Cast(e, typ) => {
+                let e = self.expr_from_ast(e, module);
+                self.convert(e, Type::from_ast(typ, module))
+            }
+            Match(e, arms) => self.switch(e, arms, None, module),
+            Index(list, index) => {
+                let list = self.expr_from_ast(list, module);
+                let index = self.expr_from_ast(index, module);
+                let element_type = list.type_().element_type().deref().clone();
+                // a constant index can be checked before running
+                let constant_index = match index {
+                    Expr::I64(i) => Some(i),
+                    Expr::I32(i) => Some(i as i64),
+                    _ => None,
+                };
+                if let Some(i) = constant_index {
+                    let err = match self.list_length(&list) {
+                        _ if i < 0 => Some(CodegenError::negative_index(&expr.location, i)),
+                        Some(len) if i as usize >= len => {
+                            Some(CodegenError::index_out_of_bounds(&expr.location, i, len))
+                        }
+                        _ => None,
+                    };
+                    if let Some(err) = err {
+                        module.errors.push(err);
+                        return Expr::Undef(element_type);
+                    }
+                }
+                let ptr_id = ID::new();
+                self.instructions.push(Instruction::ElementPtr {
+                    id: ptr_id.clone(),
+                    load_from: list,
+                    index,
+                });
+                let id = ID::new();
+                self.instructions.push(Instruction::Load {
+                    id: id.clone(),
+                    load_from: Expr::local_id(element_type.clone(), ptr_id),
+                });
+                Expr::local_id(element_type, id)
+            }
             MemberAccess(from, access) => {
                 let v = self.expr_from_ast(from, module);
                 let typ = if let Type::Named(name) = v.type_() {
@@ -540,15 +1338,30 @@ impl Body {
                     ),
                 }
             }
+            Binary(lhs, rhs, op) if op.is_logical() => self.short_circuit(lhs, rhs, op, module),
             Binary(lhs, rhs, op) => {
                 let id = ID::new();
                 let lhs = self.expr_from_ast(lhs, module);
                 let rhs = self.expr_from_ast(rhs, module);
-                let result_typ = lhs.type_();
-                let op_name = match op {
-                    Operator::Plus => "add",
-                }
-                .to_string();
+                let typ = lhs.type_();
+                let op_name = match (op, &typ) {
+                    (Operator::Equal, Type::Struct { name, .. }) if name == "string" => {
+                        return self.string_equal(lhs, rhs);
+                    }
+                    (Operator::NotEqual, Type::Struct { name, .. }) if name == "string" => {
+                        let equal = self.string_equal(lhs, rhs);
+                        return self.binary_operation("xor", equal, Expr::Bool(true));
+                    }
+                    (Operator::Pow, _) => return self.pow(lhs, rhs, &expr.location, module),
+                    (op, Type::Float(..)) => float_op_name(op),
+                    (op, _) => int_op_name(op),
+                };
+                let result_typ = if op.is_comparison() {
+                    Type::Int(1)
+                } else {
+                    typ
+                };
+                let op_name = op_name.to_string();
                 let inst = Instruction::BinaryOperation {
                     id: id.clone(),
                     op_name,
@@ -558,81 +1371,912 @@ impl Body {
                 self.instructions.push(inst);
                 Expr::local_id(result_typ, id)
             }
+            Unary(op, e) => match op {
+                // unary plus is a no-op on numbers
+                UnaryOperator::Plus => self.expr_from_ast(e, module),
+                UnaryOperator::Minus => {
+                    let operand = self.expr_from_ast(e, module);
+                    if let Some(e) = operand.negate() {
+                        return e;
+                    }
+                    let typ = operand.type_();
+                    let id = ID::new();
+                    let inst = match typ {
+                        Type::Float(..) => Instruction::FNeg {
+                            id: id.clone(),
+                            operand,
+                        },
+                        // LLVM has no integer negation, `-x` is `0 - x`
+                        _ => Instruction::BinaryOperation {
+                            id: id.clone(),
+                            op_name: "sub".to_string(),
+                            lhs: Expr::I64(0),
+                            rhs: operand,
+                        },
+                    };
+                    self.instructions.push(inst);
+                    Expr::local_id(typ, id)
+                }
+                // LLVM has no logical not, `!x` is `x xor true`
+                UnaryOperator::Not => {
+                    let operand = self.expr_from_ast(e, module);
+                    if let Expr::Bool(b) = operand {
+                        return Expr::Bool(!b);
+                    }
+                    let id = ID::new();
+                    self.instructions.push(Instruction::BinaryOperation {
+                        id: id.clone(),
+                        op_name: "xor".to_string(),
+                        lhs: operand,
+                        rhs: Expr::Bool(true),
+                    });
+                    Expr::local_id(Type::Int(1), id)
+                }
+            },
             FuncCall(f, args) => {
-                let id = self.expr_from_ast(f, module);
-                let name = match id {
+                // `b.area()` calls method `"Bar::area"` with `b` as `self`
+                if let MemberAccess(from, method) = &f.value {
+                    let receiver = self.expr_from_ast(from, module);
+                    if module.is_trait(&receiver.type_()) {
+                        return self.call_trait_method(receiver, method, args, module);
+                    }
+                    let func_name = match receiver.type_() {
+                        Type::Struct { name, .. } | Type::Named(name) => {
+                            format!("\"{}::{}\"", name, method)
+                        }
+                        t => unreachable!("call method `{}` on non-class type `{:?}`", method, t),
+                    };
+                    return self.call(&func_name, Some(receiver), args, module);
+                }
+                let name = match self.expr_from_ast(f, module) {
                     Expr::Identifier(_, name) => name,
                     e => unreachable!("call on a non-function expression: {:#?}", e),
                 };
-                match module.known_functions.get(&name).cloned() {
-                    Some(ret_type) => {
-                        let args_expr: Vec<Expr> = args
-                            .iter()
-                            .map(|arg| self.expr_from_ast(&arg.expr, module))
-                            .collect();
-                        let id = ID::new();
-                        let inst = Instruction::FunctionCall{
-                            id: id.clone(),
-                            func_name: format!("@{}", name),
-                            ret_type: ret_type.clone().into(),
-                            args_expr,
-                        };
-                        self.instructions.push(inst);
-                        Expr::local_id(ret_type.clone(), id)
-                    },
-                    None => unreachable!("no function named: `{}` which unlikely happened, semantic module must have a bug there!", name),
-                }
+                self.call(&name, None, args, module)
             }
-            Identifier(name) => match self.lookup_variable(name) {
+            Identifier(name) => match self.lookup_variable(name).cloned() {
                 Some(local_var) => match local_var {
-                    LocalVariable::Name { name, typ } => {
-                        Expr::Identifier(typ.clone(), name.clone())
+                    LocalVariable::Name { name, typ } => Expr::Identifier(typ, name),
+                    LocalVariable::Value(e) => e,
+                    LocalVariable::Slot { typ, id: slot } => {
+                        let id = ID::new();
+                        self.instructions.push(Instruction::Load {
+                            id: id.clone(),
+                            load_from: Expr::local_id(typ.clone(), slot),
+                        });
+                        Expr::local_id(typ, id)
                     }
                 },
+                // in method, `field` is a shorthand of `self.field`
+                None if self.is_field_of_self(name) => {
+                    let self_expr = ast::Expr::identifier(expr.location.clone(), "self");
+                    let member_access =
+                        ast::Expr::member_access(expr.location.clone(), self_expr, name);
+                    self.expr_from_ast(&member_access, module)
+                }
+                // a global is loaded from its address, e.g. `@xs`
+                None if module.known_variables.contains_key(name) => {
+                    let typ = module.known_variables[name].clone();
+                    let id = ID::new();
+                    self.instructions.push(Instruction::Load {
+                        id: id.clone(),
+                        load_from: Expr::Global(typ.clone(), format!("@{}", name)),
+                    });
+                    Expr::local_id(typ, id)
+                }
                 None => {
                     let ret_type = module.known_functions.get(name).expect(format!("no variable named: `{}` which unlikely happened, semantic module must have a bug there!", name).as_str());
                     Expr::Identifier(ret_type.clone(), name.clone())
                 }
             },
-            _ => Expr::from_ast(expr),
+            // only literals are left, they are always constants
+            _ => Expr::from_ast(expr, module).unwrap_or_else(|err| unreachable!("{}", err)),
+        }
+    }
+    /// pow generates `base ^ exp`, LLVM has no power instruction, so `^` calls `llvm.pow.f64`
+    /// on float, and multiplies by squaring on int, e.g. `x ^ 4` is `(x * x) * (x * x)`
+    fn pow(&mut self, base: Expr, exp: Expr, location: &Location, module: &mut Module) -> Expr {
+        if let Some(e) = Expr::try_fold(&base, &exp, &Operator::Pow) {
+            return e;
         }
+        let typ = base.type_();
+        match (typ.clone(), exp) {
+            (Type::Float(n), exp) => {
+                let typ = Type::Float(n);
+                let func_name = module.declare_intrinsic(
+                    "llvm.pow.f64",
+                    vec![typ.clone(), typ.clone()],
+                    typ.clone(),
+                );
+                let id = ID::new();
+                self.instructions.push(Instruction::FunctionCall {
+                    id: id.clone(),
+                    func_name,
+                    ret_type: typ.clone().into(),
+                    args_expr: vec![base, exp],
+                });
+                Expr::local_id(typ, id)
+            }
+            (_, Expr::I64(e)) if e >= 0 => self.multiply_out(base, e as u64),
+            // a negative exponent is `1 / base ^ -exp`, truncated like any int division
+            (_, Expr::I64(e)) => {
+                let power = self.multiply_out(base, e.unsigned_abs());
+                self.binary_operation("sdiv", Expr::I64(1), power)
+            }
+            // an exponent only known at runtime loops in the prelude
+            (_, exp) if module.known_functions.contains_key("_pow_int") => {
+                let id = ID::new();
+                self.instructions.push(Instruction::FunctionCall {
+                    id: id.clone(),
+                    func_name: "@_pow_int".to_string(),
+                    ret_type: typ.clone().into(),
+                    args_expr: vec![base, exp],
+                });
+                Expr::local_id(typ, id)
+            }
+            _ => {
+                module
+                    .errors
+                    .push(CodegenError::unsupported_operator(location, &Operator::Pow));
+                Expr::Undef(typ)
+            }
+        }
+    }
+    /// multiply_out is `base ^ exp` by squaring, it takes at most 2 multiplications per bit of
+    /// `exp`
+    fn multiply_out(&mut self, base: Expr, exp: u64) -> Expr {
+        let mut result = None;
+        let mut square = base;
+        let mut exp = exp;
+        loop {
+            if exp & 1 == 1 {
+                result = Some(match result {
+                    None => square.clone(),
+                    Some(r) => self.binary_operation("mul", r, square.clone()),
+                });
+            }
+            exp >>= 1;
+            if exp == 0 {
+                break;
+            }
+            square = self.binary_operation("mul", square.clone(), square);
+        }
+        result.unwrap_or(Expr::I64(1))
+    }
+    fn binary_operation(&mut self, op_name: &str, lhs: Expr, rhs: Expr) -> Expr {
+        let typ = lhs.type_();
+        let id = ID::new();
+        self.instructions.push(Instruction::BinaryOperation {
+            id: id.clone(),
+            op_name: op_name.to_string(),
+            lhs,
+            rhs,
+        });
+        Expr::local_id(typ, id)
+    }
+    /// c_string is the pointer to the first character of a global string literal
+    fn c_string(&mut self, string_literal: &String, module: &mut Module) -> Expr {
+        let str_literal_id = module.string_literal(string_literal);
+        let str_load_id = ID::new();
+        let array_type = Expr::CString(string_literal.clone()).type_();
+        let inst = Instruction::GEP {
+            id: str_load_id.clone(),
+            load_from: Expr::global_id(Type::Pointer(array_type.into()), str_literal_id),
+            indices: vec![0, 0],
+        };
+        self.instructions.push(inst);
+        Expr::local_id(Type::Pointer(Type::Int(8).into()), str_load_id)
+    }
+    /// call calls a known function, `receiver` is `self` of a method call
+    fn call(
+        &mut self,
+        name: &String,
+        receiver: Option<Expr>,
+        args: &Vec<Argument>,
+        module: &mut Module,
+    ) -> Expr {
+        // `print` of prelude is a builtin without body
+        if name == "print" && receiver.is_none() {
+            return self.print(&args[0], module);
+        }
+        let (ret_type, parameters) = match module.known_functions.get(name) {
+            Some(Type::Function { ret_type, parameters }) => (ret_type.deref().clone(), parameters.clone()),
+            _ => unreachable!("no function named: `{}` which unlikely happened, semantic module must have a bug there!", name),
+        };
+        let mut args_expr: Vec<Expr> = receiver.into_iter().collect();
+        for arg in args {
+            let e = match parameters.get(args_expr.len()) {
+                Some(typ) => {
+                    let e = self.expr_in_context(&arg.expr, typ, module);
+                    self.upcast(e, typ, &arg.expr.location, module)
+                }
+                None => self.expr_from_ast(&arg.expr, module),
+            };
+            args_expr.push(e);
+        }
+        let id = ID::new();
+        let inst = Instruction::FunctionCall {
+            id: id.clone(),
+            func_name: format!("@{}", name),
+            ret_type: ret_type.clone().into(),
+            args_expr,
+        };
+        self.instructions.push(inst);
+        Expr::local_id(ret_type, id)
+    }
+    /// print writes the content of string by `printf("%s", s)`, unlike `puts` it doesn't append
+    /// a newline
+    fn print(&mut self, arg: &Argument, module: &mut Module) -> Expr {
+        let s = self.expr_from_ast(&arg.expr, module);
+        let content = self.load_field(s, 0, Type::Pointer(Type::Int(8).into()));
+        let format = self.c_string(&"%s".to_string(), module);
+        let id = ID::new();
+        let inst = Instruction::FunctionCall {
+            id: id.clone(),
+            func_name: module.declare_printf(),
+            ret_type: Type::Int(32).into(),
+            args_expr: vec![format, content],
+        };
+        self.instructions.push(inst);
+        Expr::local_id(Type::Int(32), id)
+    }
+    /// call_trait_method looks up the method in vtable of trait object and calls it with the
+    /// implementor value as `self`
+    fn call_trait_method(
+        &mut self,
+        receiver: Expr,
+        method: &String,
+        args: &Vec<Argument>,
+        module: &mut Module,
+    ) -> Expr {
+        let vtable_type = match &receiver.type_() {
+            Type::Struct { fields, .. } => fields[1].typ.deref().clone(),
+            t => unreachable!("`{:?}` is not a trait object", t),
+        };
+        let vtable = self.load_field(receiver.clone(), 1, vtable_type.clone());
+        let vtable_fields = match module.lookup_type(&vtable_type.element_type().struct_name()) {
+            Type::Struct { fields, .. } => fields.clone(),
+            _ => unreachable!(),
+        };
+        let index = vtable_fields
+            .iter()
+            .position(|field| &field.name == method)
+            .expect(format!("no method named: `{}` which unlikely happened, semantic module must have a bug there!", method).as_str());
+        let method_type = vtable_fields[index].typ.deref().clone();
+        let callee = self.load_field(vtable, index, method_type.clone());
+        let value = self.load_field(receiver, 0, Type::Pointer(Type::Int(8).into()));
+        let (ret_type, parameters) = match method_type.element_type().deref() {
+            Type::Function {
+                ret_type,
+                parameters,
+            } => (ret_type.deref().clone(), parameters.clone()),
+            t => unreachable!("`{:?}` is not a function", t),
+        };
+        let mut args_expr = vec![value];
+        for arg in args {
+            let typ = &parameters[args_expr.len()];
+            let e = self.expr_in_context(&arg.expr, typ, module);
+            let e = self.upcast(e, typ, &arg.expr.location, module);
+            args_expr.push(e);
+        }
+        let id = ID::new();
+        let inst = Instruction::IndirectCall {
+            id: id.clone(),
+            callee,
+            ret_type: ret_type.clone().into(),
+            args_expr,
+        };
+        self.instructions.push(inst);
+        Expr::local_id(ret_type, id)
+    }
+    /// load_field loads the field at `index` of the struct pointed by `from`
+    fn load_field(&mut self, from: Expr, index: usize, typ: Type) -> Expr {
+        let gep_id = ID::new();
+        self.instructions.push(Instruction::GEP {
+            id: gep_id.clone(),
+            load_from: from,
+            indices: vec![0, index as u64],
+        });
+        let id = ID::new();
+        self.instructions.push(Instruction::Load {
+            id: id.clone(),
+            load_from: Expr::local_id(typ.clone(), gep_id),
+        });
+        Expr::local_id(typ, id)
+    }
+    /// upcast wraps a class value into a trait object when the context expects a trait, e.g.
+    /// pass `Circle` to parameter `s: Shape`. The trait object is allocated by `malloc` and never
+    /// freed, the same as a class value, so it is owned by no one and lives until the program
+    /// exits. A new object is made on every conversion, e.g. each iteration of a loop, while a
+    /// trait value is passed on as is
+    fn upcast(&mut self, e: Expr, typ: &Type, location: &Location, module: &mut Module) -> Expr {
+        let class_name = match e.type_() {
+            Type::Struct { name, .. } if module.is_trait(typ) && &e.type_() != typ => name,
+            _ => return e,
+        };
+        let trait_name = typ.struct_name();
+        let vtable = match module.vtable(&class_name, &trait_name, location) {
+            Ok(vtable) => vtable,
+            Err(err) => {
+                module.errors.push(err);
+                return Expr::Undef(typ.clone());
+            }
+        };
+        let malloc_id = ID::new();
+        self.instructions.push(Instruction::Malloca {
+            id: malloc_id.clone(),
+            typ: typ.clone(),
+        });
+        let object_id = ID::new();
+        self.instructions.push(Instruction::BitCast {
+            id: object_id.clone(),
+            from: Expr::local_id(Type::Pointer(Type::Int(8).into()), malloc_id),
+            target_type: typ.clone(),
+        });
+        let value_id = ID::new();
+        self.instructions.push(Instruction::BitCast {
+            id: value_id.clone(),
+            from: e,
+            target_type: Type::Pointer(Type::Int(8).into()),
+        });
+        let fields = vec![
+            Expr::local_id(Type::Pointer(Type::Int(8).into()), value_id),
+            vtable,
+        ];
+        for (i, field) in fields.into_iter().enumerate() {
+            let gep_id = ID::new();
+            self.instructions.push(Instruction::GEP {
+                id: gep_id.clone(),
+                load_from: Expr::local_id(typ.clone(), object_id.clone()),
+                indices: vec![0, i as u64],
+            });
+            self.instructions.push(Instruction::Store {
+                source: field,
+                destination: gep_id,
+            });
+        }
+        Expr::local_id(typ.clone(), object_id)
+    }
+    /// convert picks the conversion instruction by types, an integer is signed except `bool`
+    fn convert(&mut self, e: Expr, target_type: Type) -> Expr {
+        if let Some(e) = e.try_convert(&target_type) {
+            return e;
+        }
+        let op_name = match (e.type_(), &target_type) {
+            // e.g. `char` to `i32`
+            (from, to) if &from == to => return e,
+            (Type::Int(1), Type::Int(..)) => "zext",
+            (Type::Int(from), Type::Int(to)) if from < *to => "sext",
+            (Type::Int(..), Type::Int(..)) => "trunc",
+            (Type::Int(1), Type::Float(..)) => "uitofp",
+            (Type::Int(..), Type::Float(..)) => "sitofp",
+            (Type::Float(..), Type::Int(..)) => "fptosi",
+            (Type::Float(from), Type::Float(to)) if from < *to => "fpext",
+            (Type::Float(..), Type::Float(..)) => "fptrunc",
+            (from, to) => unreachable!(
+                "cast `{:?}` to `{:?}`, semantic module must have a bug there!",
+                from, to
+            ),
+        };
+        let id = ID::new();
+        self.instructions.push(Instruction::Convert {
+            id: id.clone(),
+            op_name: op_name.to_string(),
+            from: e,
+            target_type: target_type.clone(),
+        });
+        Expr::local_id(target_type, id)
+    }
+    /// short_circuit evaluates `rhs` only when `lhs` can't decide the result, e.g. `rhs` of
+    /// `lhs && rhs` is skipped when `lhs` is false, the result is merged by a `phi`
+    fn short_circuit(
+        &mut self,
+        lhs: &ast::Expr,
+        rhs: &ast::Expr,
+        op: &Operator,
+        module: &mut Module,
+    ) -> Expr {
+        // the result when `rhs` is skipped
+        let decided = op == &Operator::Or;
+        let lhs = self.expr_from_ast(lhs, module);
+        match lhs {
+            Expr::Bool(b) if b == decided => return lhs,
+            Expr::Bool(..) => return self.expr_from_ast(rhs, module),
+            _ => (),
+        }
+        let lhs_block = self.current_block();
+        let rhs_label = Label::new(ID::new());
+        let end_label = Label::new(ID::new());
+        let (if_true, if_false) = if decided {
+            (end_label.clone(), rhs_label.clone())
+        } else {
+            (rhs_label.clone(), end_label.clone())
+        };
+        self.instructions.push(Instruction::Branch {
+            cond: lhs,
+            if_true,
+            if_false,
+        });
+        self.instructions.push(Instruction::Label(rhs_label));
+        let rhs = self.expr_from_ast(rhs, module);
+        // `rhs` can end in another block, e.g. `a && (b || c)`
+        let rhs_block = self.current_block();
+        self.goto(&end_label);
+        self.instructions.push(Instruction::Label(end_label));
+        let id = ID::new();
+        self.instructions.push(Instruction::Phi {
+            id: id.clone(),
+            incoming: vec![(Expr::Bool(decided), lhs_block), (rhs, rhs_block)],
+        });
+        Expr::local_id(Type::Int(1), id)
+    }
+    /// switch jumps to the arm whose pattern equals to `e`, the `_` arm is the default, values
+    /// of arms are merged by a `phi`, each value is coerced to `expected` type if there is one
+    fn switch(
+        &mut self,
+        e: &ast::Expr,
+        arms: &[ast::MatchArm],
+        expected: Option<&Type>,
+        module: &mut Module,
+    ) -> Expr {
+        let value = self.expr_from_ast(e, module);
+        let typ = value.type_();
+        let end_label = Label::new(ID::new());
+        let labels: Vec<Rc<Label>> = arms.iter().map(|_| Label::new(ID::new())).collect();
+        let mut default = end_label.clone();
+        let mut cases = vec![];
+        for (arm, label) in arms.iter().zip(&labels) {
+            match &arm.pattern {
+                Some(pattern) => {
+                    // semantic checker ensures a pattern is a constant
+                    let pattern = Expr::from_ast(pattern, module)
+                        .unwrap_or_else(|err| unreachable!("{}", err));
+                    // a pattern out of the range of `typ` would be truncated to another value
+                    let pattern = if pattern.type_() == typ {
+                        Some(pattern)
+                    } else {
+                        pattern
+                            .try_convert(&typ)
+                            .filter(|p| p.integer_value() == pattern.integer_value())
+                    };
+                    match pattern {
+                        Some(pattern) => cases.push((pattern, label.clone())),
+                        None => {
+                            let location = &arm.pattern.as_ref().unwrap().location;
+                            module.errors.push(CodegenError::invalid_pattern(location));
+                        }
+                    }
+                }
+                None => default = label.clone(),
+            }
+        }
+        self.instructions.push(Instruction::Switch {
+            value,
+            default,
+            cases,
+        });
+        let mut incoming = vec![];
+        for (arm, label) in arms.iter().zip(labels) {
+            self.instructions.push(Instruction::Label(label));
+            let v = match expected {
+                Some(typ) => self.expr_in_context(&arm.expr, typ, module),
+                None => self.expr_from_ast(&arm.expr, module),
+            };
+            // the arm can end in another block, e.g. `1 => a && b`
+            incoming.push((v, self.current_block()));
+            self.goto(&end_label);
+        }
+        self.instructions.push(Instruction::Label(end_label));
+        match incoming[0].0.type_() {
+            // arms of `void` have no value to be merged
+            Type::Void => incoming.remove(0).0,
+            typ => {
+                let id = ID::new();
+                self.instructions.push(Instruction::Phi {
+                    id: id.clone(),
+                    incoming,
+                });
+                Expr::local_id(typ, id)
+            }
+        }
+    }
+    /// list_length is the length of a list allocated from a list literal, a list from elsewhere,
+    /// e.g. a parameter, has unknown length
+    fn list_length(&self, list: &Expr) -> Option<usize> {
+        match list {
+            Expr::LocalIdentifier(_, id) => self
+                .list_lengths
+                .iter()
+                .find(|(list_id, _)| Rc::ptr_eq(list_id, id))
+                .map(|(_, len)| *len),
+            _ => None,
+        }
+    }
+    /// alloc_list allocates `[len x element_type]` on heap, and returns the pointer to the first
+    /// element
+    fn alloc_list(&mut self, element_type: Type, len: usize) -> Expr {
+        let malloc_id = ID::new();
+        self.instructions.push(Instruction::Malloca {
+            id: malloc_id.clone(),
+            typ: Type::Array {
+                len,
+                element_type: element_type.clone().into(),
+            },
+        });
+        let list_id = ID::new();
+        let list_type = Type::Pointer(element_type.into());
+        self.instructions.push(Instruction::BitCast {
+            id: list_id.clone(),
+            from: Expr::local_id(Type::Pointer(Type::Int(8).into()), malloc_id),
+            target_type: list_type.clone(),
+        });
+        self.list_lengths.push((list_id.clone(), len));
+        Expr::local_id(list_type, list_id)
+    }
+    /// fill stores `element` to `list[0]` until `list[count - 1]`, the loop keeps generated code
+    /// in the same size for any `count`
+    fn fill(&mut self, list: Expr, element: Expr, count: usize) {
+        let entry_block = self.current_block();
+        let head_label = Label::new(ID::new());
+        let body_label = Label::new(ID::new());
+        let end_label = Label::new(ID::new());
+        self.goto(&head_label);
+        self.instructions
+            .push(Instruction::Label(head_label.clone()));
+        let index_id = ID::new();
+        let next_id = ID::new();
+        self.instructions.push(Instruction::Phi {
+            id: index_id.clone(),
+            incoming: vec![
+                (Expr::I64(0), entry_block),
+                (
+                    Expr::local_id(Type::Int(64), next_id.clone()),
+                    body_label.clone(),
+                ),
+            ],
+        });
+        let index = Expr::local_id(Type::Int(64), index_id);
+        let done_id = ID::new();
+        self.instructions.push(Instruction::BinaryOperation {
+            id: done_id.clone(),
+            op_name: "icmp eq".to_string(),
+            lhs: index.clone(),
+            rhs: Expr::I64(count as i64),
+        });
+        self.instructions.push(Instruction::Branch {
+            cond: Expr::local_id(Type::Int(1), done_id),
+            if_true: end_label.clone(),
+            if_false: body_label.clone(),
+        });
+        self.instructions.push(Instruction::Label(body_label));
+        let ptr_id = ID::new();
+        self.instructions.push(Instruction::ElementPtr {
+            id: ptr_id.clone(),
+            load_from: list,
+            index: index.clone(),
+        });
+        self.instructions.push(Instruction::Store {
+            source: element,
+            destination: ptr_id,
+        });
+        self.instructions.push(Instruction::BinaryOperation {
+            id: next_id,
+            op_name: "add".to_string(),
+            lhs: index,
+            rhs: Expr::I64(1),
+        });
+        self.goto(&head_label);
+        self.instructions.push(Instruction::Label(end_label));
+    }
+    /// current_block is the label of the block new instructions append to, it's the entry block
+    /// before any label instruction
+    fn current_block(&self) -> Rc<Label> {
+        self.instructions
+            .iter()
+            .rev()
+            .find_map(|inst| match inst {
+                Instruction::Label(label) => Some(label.clone()),
+                _ => None,
+            })
+            .unwrap_or_else(|| self.entry.clone())
+    }
+    /// string_equal compares length of two strings, and then their bytes by `memcmp` only if the
+    /// lengths are the same, a NUL in the middle of a string is compared as any other byte
+    fn string_equal(&mut self, lhs: Expr, rhs: Expr) -> Expr {
+        let lhs_length = self.load_field(lhs.clone(), 1, Type::Int(64));
+        let rhs_length = self.load_field(rhs.clone(), 1, Type::Int(64));
+        let same_length_id = ID::new();
+        self.instructions.push(Instruction::BinaryOperation {
+            id: same_length_id.clone(),
+            op_name: "icmp eq".to_string(),
+            lhs: lhs_length.clone(),
+            rhs: rhs_length,
+        });
+        let length_block = self.current_block();
+        let bytes_label = Label::new(ID::new());
+        let end_label = Label::new(ID::new());
+        self.instructions.push(Instruction::Branch {
+            cond: Expr::local_id(Type::Int(1), same_length_id),
+            if_true: bytes_label.clone(),
+            if_false: end_label.clone(),
+        });
+        self.instructions
+            .push(Instruction::Label(bytes_label.clone()));
+        let args_expr = vec![self.c_string_of(lhs), self.c_string_of(rhs), lhs_length];
+        let cmp_id = ID::new();
+        self.instructions.push(Instruction::FunctionCall {
+            id: cmp_id.clone(),
+            func_name: "@memcmp".to_string(),
+            ret_type: Type::Int(32).into(),
+            args_expr,
+        });
+        let same_bytes_id = ID::new();
+        self.instructions.push(Instruction::BinaryOperation {
+            id: same_bytes_id.clone(),
+            op_name: "icmp eq".to_string(),
+            lhs: Expr::local_id(Type::Int(32), cmp_id),
+            rhs: Expr::I32(0),
+        });
+        self.goto(&end_label);
+        self.instructions.push(Instruction::Label(end_label));
+        let id = ID::new();
+        self.instructions.push(Instruction::Phi {
+            id: id.clone(),
+            incoming: vec![
+                (Expr::Bool(false), length_block),
+                (Expr::local_id(Type::Int(1), same_bytes_id), bytes_label),
+            ],
+        });
+        Expr::local_id(Type::Int(1), id)
+    }
+    /// c_string_of loads field `value` of a string
+    fn c_string_of(&mut self, s: Expr) -> Expr {
+        let c_string_type = Type::Pointer(Type::Int(8).into());
+        let gep_id = ID::new();
+        let inst = Instruction::GEP {
+            id: gep_id.clone(),
+            load_from: s,
+            indices: vec![0, 0],
+        };
+        self.instructions.push(inst);
+        let id = ID::new();
+        let inst = Instruction::Load {
+            id: id.clone(),
+            load_from: Expr::local_id(c_string_type.clone(), gep_id),
+        };
+        self.instructions.push(inst);
+        Expr::local_id(c_string_type, id)
+    }
+}
+
+fn int_op_name(op: &Operator) -> &'static str {
+    use Operator::*;
+    match op {
+        Plus => "add",
+        Minus => "sub",
+        Multiply => "mul",
+        Divide => "sdiv",
+        Remainder => "srem",
+        Equal => "icmp eq",
+        NotEqual => "icmp ne",
+        LessThan => "icmp slt",
+        LessThanOrEqual => "icmp sle",
+        GreaterThan => "icmp sgt",
+        GreaterThanOrEqual => "icmp sge",
+        Pow => unreachable!("no instruction for `^`"),
+        And | Or => unreachable!("`{}` is short-circuited by branches", op),
+    }
+}
+
+/// float_op_name uses ordered comparison, so any comparison with NaN is false
+fn float_op_name(op: &Operator) -> &'static str {
+    use Operator::*;
+    match op {
+        Plus => "fadd",
+        Minus => "fsub",
+        Multiply => "fmul",
+        Divide => "fdiv",
+        Remainder => "frem",
+        Equal => "fcmp oeq",
+        NotEqual => "fcmp one",
+        LessThan => "fcmp olt",
+        LessThanOrEqual => "fcmp ole",
+        GreaterThan => "fcmp ogt",
+        GreaterThanOrEqual => "fcmp oge",
+        Pow => unreachable!("no instruction for `^`"),
+        And | Or => unreachable!("`{}` is short-circuited by branches", op),
     }
 }
 
 #[derive(Debug, Clone, PartialEq)]
 pub(crate) enum Expr {
+    I8(i8),
+    I16(i16),
+    I32(i32),
     I64(i64),
+    F32(f32),
     F64(f64),
     Bool(bool),
+    Char(char),
     CString(String),
     Identifier(Type, String),
     LocalIdentifier(Type, Rc<RefCell<ID>>),
     GlobalIdentifier(Type, Rc<RefCell<ID>>),
+    /// Global refers a global by its name, e.g. `@"Circle::area"`
+    Global(Type, String),
+    /// BitCast is a constant cast, e.g. method in vtable casts its `self` to `i8*`
+    BitCast(Box<Expr>, Type),
+    /// Struct is a constant struct value
+    Struct(Type, Vec<Expr>),
+    /// Array is a constant array of the element type, e.g. the data of a global list
+    Array(Type, Vec<Expr>),
+    /// Zero is the constant of the type with all bits zero, e.g. `zeroinitializer` of an array
+    Zero(Type),
+    /// Undef stands for the value of an expression failed to generate
+    Undef(Type),
 }
 
 impl Expr {
-    pub(crate) fn from_ast(a: &ast::Expr) -> Expr {
+    /// from_ast converts a constant expression, arithmetic on constants would be folded, e.g.
+    /// `x: int = 40 + 2;` would be `@x = global i64 42`
+    pub(crate) fn from_ast(a: &ast::Expr, module: &Module) -> Result<Expr, CodegenError> {
         use ExprVariant::*;
-        match &a.value {
+        let e = match &a.value {
+            Binary(lhs, rhs, op) => Expr::fold(
+                Expr::from_ast(lhs, module)?,
+                Expr::from_ast(rhs, module)?,
+                op,
+                &a.location,
+            )?,
+            // e.g. `-5'i8` is `-5 as i8`, it's folded as semantic checker does
+            Cast(e, typ) => {
+                let target_type = Type::from_ast(typ, module);
+                Expr::from_ast(e, module)?
+                    .to_constant()
+                    .and_then(|c| constant::cast(c, typ.name().as_str()))
+                    .and_then(|c| Expr::from_constant(c, &target_type))
+                    .ok_or_else(|| CodegenError::not_constant(&a.location))?
+            }
             F64(f) => Expr::F64(*f),
             Int(i) => Expr::I64(*i),
             Bool(b) => Expr::Bool(*b),
+            Char(c) => Expr::Char(*c),
             String(s) => Expr::CString(s.clone()),
-            expr => unimplemented!("codegen: expr {:#?}", expr),
+            Unary(UnaryOperator::Plus, e) => Expr::from_ast(e, module)?,
+            Unary(UnaryOperator::Minus, e) => match Expr::from_ast(e, module)?.negate() {
+                Some(e) => e,
+                None => return Err(CodegenError::not_constant(&a.location)),
+            },
+            Unary(UnaryOperator::Not, e) => match Expr::from_ast(e, module)? {
+                Expr::Bool(b) => Expr::Bool(!b),
+                _ => return Err(CodegenError::not_constant(&a.location)),
+            },
+            _ => return Err(CodegenError::not_constant(&a.location)),
+        };
+        Ok(e)
+    }
+    /// try_convert folds a cast on constant, returns `None` if `e` is not a constant
+    fn try_convert(&self, typ: &Type) -> Option<Expr> {
+        let name = match typ {
+            Type::Int(64) => "int",
+            Type::Int(32) => "i32",
+            Type::Int(16) => "i16",
+            Type::Int(8) => "i8",
+            Type::Float(64) => "f64",
+            Type::Float(32) => "f32",
+            _ => return None,
+        };
+        let c = constant::cast(self.to_constant()?, name)?;
+        Expr::from_constant(c, typ)
+    }
+    /// integer truncates `i` to an integer constant of `size` bits, e.g. `i8`
+    fn integer(size: usize, i: i64) -> Option<Expr> {
+        let e = match size {
+            8 => Expr::I8(i as i8),
+            16 => Expr::I16(i as i16),
+            32 => Expr::I32(i as i32),
+            64 => Expr::I64(i),
+            _ => return None,
+        };
+        Some(e)
+    }
+    /// integer_value is the value of an integer constant, `bool` and `char` are not integers here
+    fn integer_value(&self) -> Option<i64> {
+        match self {
+            Expr::I8(i) => Some(*i as i64),
+            Expr::I16(i) => Some(*i as i64),
+            Expr::I32(i) => Some(*i as i64),
+            Expr::I64(i) => Some(*i),
+            _ => None,
+        }
+    }
+    /// negate folds `-e` when `e` is a numeric constant
+    fn negate(&self) -> Option<Expr> {
+        let c = constant::unary(&UnaryOperator::Minus, self.to_constant()?)?;
+        Expr::from_constant(c, &self.type_())
+    }
+    /// fold is try_fold for the expression must be a constant, e.g. a global initializer
+    fn fold(
+        lhs: Expr,
+        rhs: Expr,
+        op: &Operator,
+        location: &Location,
+    ) -> Result<Expr, CodegenError> {
+        Expr::try_fold(&lhs, &rhs, op).ok_or_else(|| CodegenError::not_constant(location))
+    }
+    /// try_fold returns `None` if any operand is not a constant, it shares the folding rules with
+    /// semantic checker
+    fn try_fold(lhs: &Expr, rhs: &Expr, op: &Operator) -> Option<Expr> {
+        let c = constant::binary(lhs.to_constant()?, rhs.to_constant()?, op)?;
+        Expr::from_constant(c, &lhs.type_())
+    }
+    /// to_constant converts a constant to the form semantic checker folds, a sized number
+    /// becomes `Int` or `F64`, from_constant takes its type back
+    fn to_constant(&self) -> Option<Constant> {
+        let c = match self {
+            Expr::I8(i) => Constant::Int(*i as i64),
+            Expr::I16(i) => Constant::Int(*i as i64),
+            Expr::I32(i) => Constant::Int(*i as i64),
+            Expr::I64(i) => Constant::Int(*i),
+            Expr::F32(f) => Constant::F64(*f as f64),
+            Expr::F64(f) => Constant::F64(*f),
+            Expr::Bool(b) => Constant::Bool(*b),
+            Expr::Char(c) => Constant::Char(*c),
+            Expr::CString(s) => Constant::String(s.clone()),
+            _ => return None,
+        };
+        Some(c)
+    }
+    /// from_constant converts `c` back to a constant of `typ`, an integer truncates to the size
+    /// of `typ`, `typ` is ignored by `bool`, `char` and string
+    fn from_constant(c: Constant, typ: &Type) -> Option<Expr> {
+        let e = match (c, typ) {
+            (Constant::Int(i), Type::Int(size)) => Expr::integer(*size, i)?,
+            (Constant::Int(i), _) => Expr::I64(i),
+            (Constant::F64(f), Type::Float(32)) => Expr::F32(f as f32),
+            (Constant::F64(f), _) => Expr::F64(f),
+            (Constant::Bool(b), _) => Expr::Bool(b),
+            (Constant::Char(c), _) => Expr::Char(c),
+            (Constant::String(s), _) => Expr::CString(s),
+        };
+        Some(e)
+    }
+    /// coerce converts an integer constant to float when the context expects a float, e.g.
+    /// `x: f64 = 1;`
+    pub(crate) fn coerce(self, typ: &Type) -> Expr {
+        match (self, typ) {
+            (Expr::I64(i), Type::Float(32)) => Expr::F32(i as f32),
+            (Expr::I64(i), Type::Float(..)) => Expr::F64(i as f64),
+            (e, _) => e,
         }
     }
     pub(crate) fn type_(&self) -> Type {
         match self {
+            Expr::I8(..) => Type::Int(8),
+            Expr::I16(..) => Type::Int(16),
+            Expr::I32(..) => Type::Int(32),
             Expr::I64(..) => Type::Int(64),
+            Expr::F32(..) => Type::Float(32),
             Expr::F64(..) => Type::Float(64),
             Expr::Bool(..) => Type::Int(1),
+            Expr::Char(..) => Type::Int(32),
+            // C string ends with NUL
             Expr::CString(s) => Type::Array {
-                len: s.len(),
+                len: s.len() + 1,
                 element_type: Type::Int(8).into(),
             },
             Expr::Identifier(typ, ..) => typ.clone(),
             Expr::LocalIdentifier(typ, ..) => typ.clone(),
             Expr::GlobalIdentifier(typ, ..) => typ.clone(),
+            Expr::Global(typ, ..) => typ.clone(),
+            Expr::BitCast(_, typ) => typ.clone(),
+            Expr::Struct(typ, ..) => typ.clone(),
+            Expr::Array(element_type, elements) => Type::Array {
+                len: elements.len(),
+                element_type: element_type.clone().into(),
+            },
+            Expr::Zero(typ) => typ.clone(),
+            Expr::Undef(typ) => typ.clone(),
         }
     }
 
@@ -1,4 +1,6 @@
+use super::debug_info::DebugInfo;
 use super::ir;
+use crate::lexer::Location;
 
 pub trait LLVMValue {
     fn llvm_represent(&self) -> String;
@@ -7,22 +9,43 @@ pub trait LLVMValue {
 impl LLVMValue for ir::Module {
     fn llvm_represent(&self) -> String {
         let mut s = String::new();
+        s.push_str(format!("; ModuleID = '{}'\n", self.name).as_str());
+        s.push_str(format!("source_filename = \"{}\"\n", self.name).as_str());
+        let mut debug_info = if self.debug_info {
+            Some(DebugInfo::new(&self.name))
+        } else {
+            None
+        };
         for (_, t) in &self.types {
             s.push_str(t.llvm_def().as_str());
             s.push_str("\n");
         }
         for v in &self.variables {
+            s.push_str(self.provenance_of(&v.location).as_str());
             s.push_str(v.llvm_represent().as_str());
             s.push_str("\n");
         }
         for (_, f) in &self.functions {
-            s.push_str(f.llvm_represent().as_str());
+            s.push_str(self.provenance_of(&f.location).as_str());
+            s.push_str(f.represent(debug_info.as_mut()).as_str());
             s.push_str("\n");
         }
+        if let Some(debug_info) = debug_info {
+            s.push_str(debug_info.llvm_represent().as_str());
+        }
         s
     }
 }
 
+impl ir::Module {
+    fn provenance_of(&self, location: &Option<Location>) -> String {
+        match location {
+            Some(location) if self.provenance => format!("; {}\n", location),
+            _ => "".to_string(),
+        }
+    }
+}
+
 impl LLVMValue for ir::GlobalName {
     fn llvm_represent(&self) -> String {
         use ir::GlobalName::*;
@@ -43,6 +66,7 @@ impl ir::Instruction {
                     false
                 }
             }
+            ir::Instruction::IndirectCall { ret_type, .. } => ret_type == &Box::new(ir::Type::Void),
             _ => false,
         }
     }
@@ -80,6 +104,19 @@ impl LLVMValue for ir::Instruction {
                 }
                 s
             }
+            ElementPtr {
+                id,
+                load_from,
+                index,
+            } => format!(
+                "%{id} = getelementptr {target}, {ptr_to_target} {load_from}, {index_type} {index}",
+                id = id.borrow(),
+                target = load_from.type_().element_type().llvm_represent(),
+                ptr_to_target = load_from.type_().llvm_represent(),
+                load_from = load_from.llvm_represent(),
+                index_type = index.type_().llvm_represent(),
+                index = index.llvm_represent()
+            ),
             Return(e) => match e {
                 None => "ret void".to_string(),
                 Some(ex) => {
@@ -122,33 +159,62 @@ impl LLVMValue for ir::Instruction {
                 s.push_str("call ");
                 s.push_str(format!("{} ", ret_type.llvm_represent()).as_str());
                 s.push_str(func_name.as_str());
-                s.push_str("(");
-                for (index, arg_expr) in args_expr.iter().enumerate() {
-                    s.push_str(arg_expr.type_().llvm_represent().as_str());
-                    s.push_str(" ");
-                    s.push_str(arg_expr.llvm_represent().as_str());
-                    if index < args_expr.len() - 1 {
-                        s.push_str(", ");
-                    }
+                s.push_str(llvm_arguments(args_expr).as_str());
+                s
+            }
+            IndirectCall {
+                id,
+                callee,
+                ret_type,
+                args_expr,
+            } => {
+                let mut s = String::new();
+                if !self.return_void() {
+                    s.push_str(format!("%{} = ", id.borrow()).as_str());
                 }
-                s.push_str(")");
+                s.push_str("call ");
+                s.push_str(format!("{} ", ret_type.llvm_represent()).as_str());
+                s.push_str(callee.llvm_represent().as_str());
+                s.push_str(llvm_arguments(args_expr).as_str());
                 s
             }
+            FNeg { id, operand } => format!(
+                "%{} = fneg {} {}",
+                id.borrow(),
+                operand.type_().llvm_represent(),
+                operand.llvm_represent()
+            ),
+            // size of type is in bits, but malloc takes bytes
             Malloca { id, typ } => format!(
                 "%{id} = call i8* @malloc(i64 {type_size})",
                 id = id.borrow(),
-                type_size = typ.size()
+                type_size = typ.struct_size() / 8
             ),
             BitCast {
                 id,
-                from_id,
+                from,
                 target_type,
             } => format!(
-                "%{id} = bitcast i8* %{from} to {target_type}",
+                "%{id} = bitcast {from_type} {from} to {target_type}",
                 id = id.borrow(),
-                from = from_id.borrow(),
+                from_type = from.type_().llvm_represent(),
+                from = from.llvm_represent(),
                 target_type = target_type.llvm_represent()
             ),
+            Convert {
+                id,
+                op_name,
+                from,
+                target_type,
+            } => format!(
+                "%{id} = {op_name} {from_type} {from} to {target_type}",
+                id = id.borrow(),
+                op_name = op_name,
+                from_type = from.type_().llvm_represent(),
+                from = from.llvm_represent(),
+                target_type = target_type.llvm_represent()
+            ),
+            Alloca { id, typ } => format!("%{} = alloca {}", id.borrow(), typ.llvm_represent()),
             Store {
                 source,
                 destination,
@@ -159,6 +225,46 @@ impl LLVMValue for ir::Instruction {
                 (ir::Type::Pointer(source.type_().into())).llvm_represent(),
                 destination.borrow()
             ),
+            InsertValue {
+                id,
+                aggregate,
+                value,
+                index,
+            } => format!(
+                "%{id} = insertvalue {aggregate_type} {aggregate}, {value_type} {value}, {index}",
+                id = id.borrow(),
+                aggregate_type = aggregate.type_().llvm_represent(),
+                aggregate = aggregate.llvm_represent(),
+                value_type = value.type_().llvm_represent(),
+                value = value.llvm_represent(),
+                index = index
+            ),
+            ExtractValue {
+                id,
+                aggregate,
+                index,
+            } => format!(
+                "%{} = extractvalue {} {}, {}",
+                id.borrow(),
+                aggregate.type_().llvm_represent(),
+                aggregate.llvm_represent(),
+                index
+            ),
+            Phi { id, incoming } => {
+                let typ = incoming[0].0.type_();
+                let incoming: Vec<String> = incoming
+                    .iter()
+                    .map(|(value, label)| {
+                        format!("[ {}, %{} ]", value.llvm_represent(), label.id.borrow())
+                    })
+                    .collect();
+                format!(
+                    "%{} = phi {} {}",
+                    id.borrow(),
+                    typ.llvm_represent(),
+                    incoming.join(", ")
+                )
+            }
             Branch {
                 cond,
                 if_true,
@@ -170,12 +276,51 @@ impl LLVMValue for ir::Instruction {
                 if_true.llvm_represent(),
                 if_false.llvm_represent(),
             ),
+            Switch {
+                value,
+                default,
+                cases,
+            } => {
+                let cases: Vec<String> = cases
+                    .iter()
+                    .map(|(case, label)| {
+                        format!(
+                            "{} {}, {}",
+                            case.type_().llvm_represent(),
+                            case.llvm_represent(),
+                            label.llvm_represent()
+                        )
+                    })
+                    .collect();
+                format!(
+                    "switch {} {}, {} [ {} ]",
+                    value.type_().llvm_represent(),
+                    value.llvm_represent(),
+                    default.llvm_represent(),
+                    cases.join(" ")
+                )
+            }
             Goto(block) => format!("br {}", block.llvm_represent()),
             Label(label) => format!("; <label>:{}:", label.id.borrow()),
         }
     }
 }
 
+/// llvm_arguments is the argument list of a call, e.g. `(i64 1, double 0x4000000000000000)`
+fn llvm_arguments(args_expr: &Vec<ir::Expr>) -> String {
+    let args: Vec<String> = args_expr
+        .iter()
+        .map(|arg_expr| {
+            format!(
+                "{} {}",
+                arg_expr.type_().llvm_represent(),
+                arg_expr.llvm_represent()
+            )
+        })
+        .collect();
+    format!("({})", args.join(", "))
+}
+
 impl LLVMValue for ir::Label {
     fn llvm_represent(&self) -> String {
         format!("label %{}", self.id.borrow())
@@ -184,14 +329,29 @@ impl LLVMValue for ir::Label {
 
 impl LLVMValue for ir::Body {
     fn llvm_represent(&self) -> String {
+        self.represent(None)
+    }
+}
+
+impl ir::Body {
+    /// represent attaches `!dbg` location to instructions when debug info and the subprogram of
+    /// function are given
+    fn represent(&self, mut debug_info: Option<(&mut DebugInfo, usize)>) -> String {
         let mut s = String::new();
-        for instruction in &self.instructions {
+        for (index, instruction) in self.instructions.iter().enumerate() {
             match instruction {
                 ir::Instruction::Label(..) => {
                     s.push_str(format!("{}\n", instruction.llvm_represent()).as_str());
                 }
                 _ => {
-                    s.push_str(format!("  {}\n", instruction.llvm_represent()).as_str());
+                    s.push_str(format!("  {}", instruction.llvm_represent()).as_str());
+                    if let (Some((debug_info, scope)), Some(location)) =
+                        (&mut debug_info, self.location_of(index))
+                    {
+                        let id = debug_info.location(location, *scope);
+                        s.push_str(format!(", !dbg !{}", id).as_str());
+                    }
+                    s.push_str("\n");
                 }
             }
         }
@@ -201,6 +361,13 @@ impl LLVMValue for ir::Body {
 
 impl LLVMValue for ir::Function {
     fn llvm_represent(&self) -> String {
+        self.represent(None)
+    }
+}
+
+impl ir::Function {
+    /// represent emits a subprogram for the function definition when debug info is given
+    fn represent(&self, debug_info: Option<&mut DebugInfo>) -> String {
         let mut s = String::new();
         let is_declaration = self.body.is_none();
         if is_declaration {
@@ -220,13 +387,24 @@ impl LLVMValue for ir::Function {
                 s.push_str(", ");
             }
         }
+        if self.variadic {
+            s.push_str(", ...");
+        }
         s.push_str(")");
+        let debug_info = match (debug_info, &self.body, &self.location) {
+            (Some(debug_info), Some(..), Some(location)) => {
+                let subprogram = debug_info.subprogram(self.name.trim_start_matches('@'), location);
+                s.push_str(format!(" !dbg !{}", subprogram).as_str());
+                Some((debug_info, subprogram))
+            }
+            _ => None,
+        };
         match &self.body {
             Some(b) => {
                 s.push_str(" {\n");
-                s.push_str(b.llvm_represent().as_str());
+                s.push_str(b.represent(debug_info).as_str());
                 match self.ret_typ {
-                    ir::Type::Void => {
+                    ir::Type::Void if !b.end_with_terminator() => {
                         s.push_str("  ret void\n");
                     }
                     _ => {}
@@ -244,7 +422,11 @@ impl LLVMValue for ir::Variable {
         let mut s = String::new();
         s.push_str(self.name.llvm_represent().as_str());
         s.push_str(" = ");
-        s.push_str("global ");
+        match self.name {
+            // string literal is an anonymous constant, only this module can refer it
+            ir::GlobalName::ID(..) => s.push_str("private unnamed_addr constant "),
+            ir::GlobalName::String(..) => s.push_str("global "),
+        }
         s.push_str(self.expr.type_().llvm_represent().as_str());
         s.push_str(" ");
         s.push_str(self.expr.llvm_represent().as_str());
@@ -257,12 +439,25 @@ impl LLVMValue for ir::Type {
         use ir::Type::*;
         match self {
             Void => format!("void"),
-            Float(n) => format!("f{}", n),
+            Float(32) => format!("float"),
+            Float(_) => format!("double"),
             Int(n) => format!("i{}", n),
             Pointer(typ) => format!("{}*", typ.llvm_represent()),
             Array { len, element_type } => format!("[{} x {}]", len, element_type.llvm_represent()),
             Struct { name, .. } => format!("%{}*", name),
             Named(name) => format!("%{}", name),
+            Function {
+                ret_type,
+                parameters,
+            } => {
+                let parameters: Vec<String> =
+                    parameters.iter().map(|p| p.llvm_represent()).collect();
+                format!("{} ({})", ret_type.llvm_represent(), parameters.join(", "))
+            }
+            Tuple(types) => {
+                let types: Vec<String> = types.iter().map(|t| t.llvm_represent()).collect();
+                format!("{{ {} }}", types.join(", "))
+            }
         }
     }
 }
@@ -278,7 +473,7 @@ impl ir::Type {
                 for (index, field) in fields.iter().enumerate() {
                     s.push_str(field.typ.llvm_represent().as_str());
                     if index < fields.len() - 1 {
-                        s.push_str(" ");
+                        s.push_str(", ");
                     }
                 }
                 s.push_str(" }");
@@ -293,13 +488,65 @@ impl LLVMValue for ir::Expr {
     fn llvm_represent(&self) -> String {
         use ir::Expr;
         match self {
-            Expr::F64(f) => format!("{}", f),
+            // LLVM only accepts decimal float which is exact in binary, hex form is always fine
+            Expr::F64(f) => format!("0x{:016X}", f.to_bits()),
+            // LLVM writes a `float` constant as the `double` of the same value
+            Expr::F32(f) => format!("0x{:016X}", (*f as f64).to_bits()),
+            Expr::I8(i) => format!("{}", i),
+            Expr::I16(i) => format!("{}", i),
+            Expr::I32(i) => format!("{}", i),
             Expr::I64(i) => format!("{}", i),
             Expr::Bool(b) => format!("{}", b),
-            Expr::CString(s_l) => format!("c\"{}\"", s_l),
+            Expr::Char(c) => format!("{}", *c as u32),
+            Expr::CString(s_l) => {
+                // `"`, `\` and non-printable bytes must be written as `\XX` in LLVM
+                let mut s = String::new();
+                for b in s_l.bytes() {
+                    match b {
+                        0x20..=0x7E if b != b'"' && b != b'\\' => s.push(b as char),
+                        _ => s.push_str(format!("\\{:02X}", b).as_str()),
+                    }
+                }
+                format!("c\"{}\\00\"", s)
+            }
             Expr::Identifier(_, name) => format!("%{}", name),
             Expr::LocalIdentifier(_, id) => format!("%{}", id.borrow()),
             Expr::GlobalIdentifier(_, id) => format!("@{}", id.borrow()),
+            Expr::Global(_, name) => name.clone(),
+            Expr::BitCast(e, typ) => format!(
+                "bitcast ({} {} to {})",
+                e.type_().llvm_represent(),
+                e.llvm_represent(),
+                typ.llvm_represent()
+            ),
+            Expr::Struct(_, fields) => {
+                let fields: Vec<String> = fields
+                    .iter()
+                    .map(|field| {
+                        format!(
+                            "{} {}",
+                            field.type_().llvm_represent(),
+                            field.llvm_represent()
+                        )
+                    })
+                    .collect();
+                format!("{{ {} }}", fields.join(", "))
+            }
+            Expr::Array(_, elements) => {
+                let elements: Vec<String> = elements
+                    .iter()
+                    .map(|element| {
+                        format!(
+                            "{} {}",
+                            element.type_().llvm_represent(),
+                            element.llvm_represent()
+                        )
+                    })
+                    .collect();
+                format!("[{}]", elements.join(", "))
+            }
+            Expr::Zero(_) => "zeroinitializer".to_string(),
+            Expr::Undef(_) => "undef".to_string(),
         }
     }
 }
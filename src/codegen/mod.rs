@@ -1,20 +1,53 @@
 use crate::ast::*;
 use crate::codegen::tag::CodegenTag;
+pub use error::CodegenError;
 
+mod debug_info;
+mod error;
 pub mod formatter;
 pub mod ir;
 pub mod llvm;
+pub mod source_map;
 mod tag;
 
-pub struct CodeGenerator {}
+pub struct CodeGenerator {
+    provenance: bool,
+    debug_info: bool,
+}
 
 impl CodeGenerator {
     pub fn new() -> CodeGenerator {
-        CodeGenerator {}
+        CodeGenerator {
+            provenance: false,
+            debug_info: false,
+        }
+    }
+    /// with_provenance comments source location before each function and global in output, e.g.
+    /// `; main.elz:1:0`
+    pub fn with_provenance(mut self) -> CodeGenerator {
+        self.provenance = true;
+        self
+    }
+    /// with_debug_info emits DWARF line tables, so a debugger like gdb or lldb can step through
+    /// source lines, every instruction has a `!dbg` location of the statement generates it
+    pub fn with_debug_info(mut self) -> CodeGenerator {
+        self.debug_info = true;
+        self
     }
 
-    pub fn generate_module(&self, asts: &Vec<TopAst>) -> ir::Module {
-        let mut module = ir::Module::new();
+    pub fn generate_module(&self, name: &str, asts: &Vec<TopAst>) -> ir::Module {
+        let mut module = ir::Module::new(name);
+        module.provenance = self.provenance;
+        module.debug_info = self.debug_info;
+        // types must be ready before signatures, e.g. `area(s: Shape): f64;`
+        for top in asts {
+            use TopAst::*;
+            match &top {
+                Class(c) if !is_builtin_class(c) => module.push_type(&c.name, &c.members),
+                Trait(t) => module.push_trait(t),
+                _ => {}
+            }
+        }
         for top in asts {
             use TopAst::*;
             match &top {
@@ -25,8 +58,23 @@ impl CodeGenerator {
                 Variable(v) => {
                     module.remember_variable(v);
                 }
-                Class(_) => {}
-                Trait(_) => unimplemented!(),
+                Class(c) => {
+                    if is_builtin_class(c) {
+                        continue;
+                    }
+                    for member in &c.members {
+                        match member {
+                            ClassMember::StaticMethod(method) => {
+                                module.remember_method(&c.name, method, true)
+                            }
+                            ClassMember::Method(method) => {
+                                module.remember_method(&c.name, method, false)
+                            }
+                            _ => (),
+                        }
+                    }
+                }
+                Trait(_) => {}
             }
         }
         for top in asts {
@@ -41,21 +89,25 @@ impl CodeGenerator {
                     module.push_function(func);
                 }
                 Variable(v) => {
-                    let var = ir::Variable::new(v.name.clone(), ir::Expr::from_ast(&v.expr));
-                    module.push_variable(var);
+                    let typ = ir::Type::from_ast(&v.typ, &module);
+                    let var = match (&typ, &v.expr.value) {
+                        (ir::Type::Pointer(element_type), ExprVariant::List(..))
+                        | (ir::Type::Pointer(element_type), ExprVariant::ListRepeat(..)) => {
+                            ir::Variable::list_from_ast(v, element_type, &mut module)
+                        }
+                        _ => ir::Expr::from_ast(&v.expr, &module).map(|expr| {
+                            ir::Variable::new(v.name.clone(), &v.location, expr.coerce(&typ))
+                        }),
+                    };
+                    match var {
+                        Ok(var) => module.push_variable(var),
+                        Err(err) => module.errors.push(err),
+                    }
                 }
                 Class(c) => {
-                    match c.name.as_str() {
-                        // FIXME: provide a tag, e.g.
-                        // ```
-                        // @Codegen(Omit)
-                        // class int {}
-                        // ```
-                        "void" | "int" | "f64" | "bool" | "_c_string" | "List" => continue,
-                        _ => {}
+                    if is_builtin_class(c) {
+                        continue;
                     }
-                    module.push_type(&c.name, &c.members);
-
                     for member in &c.members {
                         match member {
                             ClassMember::StaticMethod(static_method) => {
@@ -70,7 +122,11 @@ impl CodeGenerator {
                                 let mut method = method.clone();
                                 method.parameters.insert(
                                     0,
-                                    Parameter::new("self", ParsedType::TypeName(c.name.clone())),
+                                    Parameter::new(
+                                        method.location.clone(),
+                                        "self",
+                                        ParsedType::TypeName(c.name.clone()),
+                                    ),
                                 );
                                 let func = ir::Function::from_ast(
                                     &method,
@@ -83,12 +139,26 @@ impl CodeGenerator {
                         }
                     }
                 }
-                Trait(_) => unimplemented!(),
+                // FIXME: generate default methods once trait can be dispatched
+                Trait(_) => {}
             }
         }
         module
     }
 }
 
+fn is_builtin_class(c: &Class) -> bool {
+    match c.name.as_str() {
+        // FIXME: provide a tag, e.g.
+        // ```
+        // @Codegen(Omit)
+        // class int {}
+        // ```
+        "void" | "int" | "i8" | "i16" | "i32" | "f64" | "f32" | "bool" | "char" | "_c_string"
+        | "List" => true,
+        _ => false,
+    }
+}
+
 #[cfg(test)]
 mod tests;
@@ -0,0 +1,51 @@
+use super::ir;
+use super::llvm::LLVMValue;
+use crate::lexer::Location;
+
+/// source_map maps each global and function in generated IR to the source location defines it, in
+/// JSON, e.g. `[{"symbol": "@main", "file": "main.elz", "line": 1, "column": 0}]`
+///
+/// String literal globals are not in the map since they have no definition in source.
+pub fn source_map(module: &ir::Module) -> String {
+    let mut entries = vec![];
+    for v in &module.variables {
+        if let Some(location) = &v.location {
+            entries.push(entry(&v.name.llvm_represent(), location));
+        }
+    }
+    let mut functions: Vec<_> = module.functions.values().collect();
+    functions.sort_by(|a, b| a.name.cmp(&b.name));
+    for f in functions {
+        if let Some(location) = &f.location {
+            entries.push(entry(&f.name, location));
+        }
+    }
+    if entries.is_empty() {
+        return "[]".to_string();
+    }
+    format!("[\n  {}\n]", entries.join(",\n  "))
+}
+
+fn entry(symbol: &str, location: &Location) -> String {
+    format!(
+        "{{\"symbol\": {}, \"file\": {}, \"line\": {}, \"column\": {}}}",
+        json_string(symbol),
+        json_string(location.file_name()),
+        location.line(),
+        location.column()
+    )
+}
+
+fn json_string(s: &str) -> String {
+    let mut result = String::from("\"");
+    for c in s.chars() {
+        match c {
+            '"' => result.push_str("\\\""),
+            '\\' => result.push_str("\\\\"),
+            c if (c as u32) < 0x20 => result.push_str(format!("\\u{:04x}", c as u32).as_str()),
+            c => result.push(c),
+        }
+    }
+    result.push('"');
+    result
+}
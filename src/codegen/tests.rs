@@ -21,6 +21,154 @@ fn global_variable() {
     assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 1");
 }
 
+#[test]
+fn global_variable_with_inferred_type() {
+    let code = "
+    a := 10;
+    b := 1.5;
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@a = global i64 10");
+    assert_eq!(
+        module.variables[1].llvm_represent(),
+        format!("@b = global double 0x{:016X}", 1.5f64.to_bits())
+    );
+}
+
+#[test]
+fn global_variable_with_constant_expression() {
+    let code = "
+    x: int = 1 + 2 + 39;
+    y: int = +40 + 2;
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 42");
+    assert_eq!(module.variables[1].llvm_represent(), "@y = global i64 42");
+}
+
+#[test]
+fn global_variable_cannot_fold_is_reported() {
+    let code = "x: int = 1 / 0;";
+    let module = gen_code(code);
+    assert_eq!(
+        module
+            .variables
+            .iter()
+            .any(|v| v.name.llvm_represent() == "@x"),
+        false
+    );
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(errors, vec!["cannot evaluate constant expression"]);
+}
+
+#[test]
+fn f64_is_double() {
+    let code = "id(v: f64): f64 = v;";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@id").unwrap().llvm_represent(),
+        "define double @id(double %v) {
+  ret double %v
+}"
+    );
+}
+
+#[test]
+fn char_literal() {
+    let code = "
+    c: char = 'λ';
+    newline(): char = '\\n';
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@c = global i32 955");
+    assert_eq!(
+        module.functions.get("@newline").unwrap().llvm_represent(),
+        "define i32 @newline() {
+  ret i32 10
+}"
+    );
+}
+
+#[test]
+fn char_literal_with_unicode_escape() {
+    let code = "
+    a: char = 'A';
+    x: char = '\\x41';
+    smile: char = '\\u{1F600}';
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@a = global i32 65");
+    assert_eq!(module.variables[1].llvm_represent(), "@x = global i32 65");
+    assert_eq!(
+        module.variables[2].llvm_represent(),
+        "@smile = global i32 128512"
+    );
+}
+
+#[test]
+fn radix_integer_literal() {
+    let code = "x: int = 0xFF + 0b1010;";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 265");
+}
+
+#[test]
+fn composed_number_literal() {
+    let code = "
+    x: int = 0xFF_FF;
+    y: int = 1_000 + 0o17;
+    z: f64 = 1_000.5e3;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.variables[0].llvm_represent(),
+        "@x = global i64 65535"
+    );
+    assert_eq!(module.variables[1].llvm_represent(), "@y = global i64 1015");
+    assert_eq!(
+        module.variables[2].llvm_represent(),
+        format!("@z = global double 0x{:016X}", 1000500f64.to_bits())
+    );
+}
+
+#[test]
+fn integer_literal_coerce_to_float() {
+    let code = "
+    x: f64 = 3;
+    y: f64 = -1;
+    z: f64 = 1 + 1;
+    foo(): f64 = 1;
+    bar(): f64 {
+      return -(2 * 3);
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.variables[0].llvm_represent(),
+        "@x = global double 0x4008000000000000"
+    );
+    assert_eq!(
+        module.variables[1].llvm_represent(),
+        "@y = global double 0xBFF0000000000000"
+    );
+    assert_eq!(
+        module.variables[2].llvm_represent(),
+        "@z = global double 0x4000000000000000"
+    );
+    assert_eq!(
+        module.functions.get("@bar").unwrap().llvm_represent(),
+        "define double @bar() {
+  ret double 0xC018000000000000
+}"
+    );
+    assert_eq!(
+        module.functions.get("@foo").unwrap().llvm_represent(),
+        "define double @foo() {
+  ret double 0x3FF0000000000000
+}"
+    );
+}
+
 #[test]
 fn test_return_value() {
     let code = "foo(): int = 1;";
@@ -72,6 +220,48 @@ fn test_function_call() {
     )
 }
 
+#[test]
+fn final_expression_is_returned() {
+    let code = "
+    inc(x: int): int {
+      x + 1
+    }
+    hello(): void {
+      println(\"hello\")
+    }
+    define_only(): void {
+      y: int = 1;
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@inc").unwrap().llvm_represent(),
+        "define i64 @inc(i64 %x) {
+  %1 = add i64 %x, 1
+  ret i64 %1
+}"
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@hello")
+            .unwrap()
+            .llvm_represent()
+            .ends_with("  ret void\n}"),
+        true
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@define_only")
+            .unwrap()
+            .llvm_represent(),
+        "define void @define_only() {
+  ret void
+}"
+    );
+}
+
 #[test]
 fn binary_expr() {
     let code = "
@@ -88,68 +278,1552 @@ fn binary_expr() {
 }
 
 #[test]
-fn test_class_define() {
+fn not_equal_expr() {
     let code = "
-    class Foo {
-      x: int;
-      ::new(): Foo;
-      bar(): void {}
-    }";
+    ne_int(a: int, b: int): bool = a != b;
+    ne_f64(a: f64, b: f64): bool = a != b;
+    x: bool = 1 != 2;
+    ";
     let module = gen_code(code);
     assert_eq!(
-        module.types.get("Foo").unwrap().llvm_def(),
-        "%Foo = type { i64 }"
+        module.functions.get("@ne_int").unwrap().llvm_represent(),
+        "define i1 @ne_int(i64 %a, i64 %b) {
+  %1 = icmp ne i64 %a, %b
+  ret i1 %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@ne_f64").unwrap().llvm_represent(),
+        "define i1 @ne_f64(double %a, double %b) {
+  %1 = fcmp one double %a, %b
+  ret i1 %1
+}"
+    );
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i1 true");
+}
+
+#[test]
+fn float_comparison() {
+    let code = "
+    gt(a: f64, b: f64): bool = a > b;
+    eq(a: f32, b: f32): bool = a == b;
+    add(a: i32, b: i32): i32 = a + b;
+    x: bool = 3.0 > 2.0;
+    y: bool = 1.5 == 1.5;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@gt").unwrap().llvm_represent(),
+        "define i1 @gt(double %a, double %b) {
+  %1 = fcmp ogt double %a, %b
+  ret i1 %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@eq").unwrap().llvm_represent(),
+        "define i1 @eq(float %a, float %b) {
+  %1 = fcmp oeq float %a, %b
+  ret i1 %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@add").unwrap().llvm_represent(),
+        "define i32 @add(i32 %a, i32 %b) {
+  %1 = add i32 %a, %b
+  ret i32 %1
+}"
+    );
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i1 true");
+    assert_eq!(module.variables[1].llvm_represent(), "@y = global i1 true");
+}
+
+#[test]
+fn arithmetic_and_comparison_expr() {
+    let code = "
+    x: int = (1 + 2) * 3 - 10 / 2;
+    y: bool = 1.5 < 2.0;
+    lt(a: int, b: int): bool = a - 1 < b * 2;
+    div(a: f64, b: f64): f64 = a / b;
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 4");
+    assert_eq!(module.variables[1].llvm_represent(), "@y = global i1 true");
+    assert_eq!(
+        module.functions.get("@lt").unwrap().llvm_represent(),
+        "define i1 @lt(i64 %a, i64 %b) {
+  %1 = sub i64 %a, 1
+  %2 = mul i64 %b, 2
+  %3 = icmp slt i64 %1, %2
+  ret i1 %3
+}"
+    );
+    assert_eq!(
+        module.functions.get("@div").unwrap().llvm_represent(),
+        "define double @div(double %a, double %b) {
+  %1 = fdiv double %a, %b
+  ret double %1
+}"
+    );
+}
+
+#[test]
+fn string_equal_expr() {
+    let code = "
+    str_eq(a: string, b: string): bool = a == b;
+    str_ne(a: string, b: string): bool = a != b;
+    x: bool = \"a\" == \"a\";
+    y: bool = \"a\" == \"b\";
+    z: bool = \"a\" != \"b\";
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@str_eq").unwrap().llvm_represent(),
+        "define i1 @str_eq(%string* %a, %string* %b) {
+  %1 = getelementptr %string, %string* %a, i32 0, i32 1
+  %2 = load i64, i64* %1
+  %3 = getelementptr %string, %string* %b, i32 0, i32 1
+  %4 = load i64, i64* %3
+  %5 = icmp eq i64 %2, %4
+  br i1 %5, label %6, label %13
+; <label>:6:
+  %7 = getelementptr %string, %string* %a, i32 0, i32 0
+  %8 = load i8*, i8** %7
+  %9 = getelementptr %string, %string* %b, i32 0, i32 0
+  %10 = load i8*, i8** %9
+  %11 = call i32 @memcmp(i8* %8, i8* %10, i64 %2)
+  %12 = icmp eq i32 %11, 0
+  br label %13
+; <label>:13:
+  %14 = phi i1 [ false, %0 ], [ %12, %6 ]
+  ret i1 %14
+}"
+    );
+    assert_eq!(
+        module.functions.get("@str_ne").unwrap().llvm_represent(),
+        "define i1 @str_ne(%string* %a, %string* %b) {
+  %1 = getelementptr %string, %string* %a, i32 0, i32 1
+  %2 = load i64, i64* %1
+  %3 = getelementptr %string, %string* %b, i32 0, i32 1
+  %4 = load i64, i64* %3
+  %5 = icmp eq i64 %2, %4
+  br i1 %5, label %6, label %13
+; <label>:6:
+  %7 = getelementptr %string, %string* %a, i32 0, i32 0
+  %8 = load i8*, i8** %7
+  %9 = getelementptr %string, %string* %b, i32 0, i32 0
+  %10 = load i8*, i8** %9
+  %11 = call i32 @memcmp(i8* %8, i8* %10, i64 %2)
+  %12 = icmp eq i32 %11, 0
+  br label %13
+; <label>:13:
+  %14 = phi i1 [ false, %0 ], [ %12, %6 ]
+  %15 = xor i1 %14, true
+  ret i1 %15
+}"
     );
+    assert_eq!(module.variables[2].llvm_represent(), "@z = global i1 true");
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i1 true");
+    assert_eq!(module.variables[1].llvm_represent(), "@y = global i1 false");
+}
+
+#[test]
+fn escape_string_literal() {
+    let code = "
+    main(): void {
+      println(\"say \\\"hi\\\"\\n\");
+    }
+    ";
+    let module = gen_code(code);
     assert_eq!(
         module
-            .functions
-            .get("@\"Foo::new\"")
-            .unwrap()
-            .llvm_represent(),
-        "declare %Foo* @\"Foo::new\"()"
+            .variables
+            .iter()
+            .any(|v| v.expr.llvm_represent() == "c\"say \\22hi\\22\\0A\\00\""),
+        true
+    );
+}
+
+#[test]
+fn pow_is_right_associative() {
+    let code = "
+    x: int = 2 ^ 3 ^ 2;
+    foo(): int = 1 + 2 ^ 3;
+    ";
+    let module = gen_code(code);
+    // (2 ^ 3) ^ 2 would be 64
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 512");
+    assert_eq!(
+        module.functions.get("@foo").unwrap().llvm_represent(),
+        "define i64 @foo() {
+  %1 = add i64 1, 8
+  ret i64 %1
+}"
+    );
+}
+
+#[test]
+fn pow_on_non_constant() {
+    let code = "
+    square(x: int): int = x ^ 2;
+    pow5(x: int): int = x ^ 5;
+    fpow(a: f64, b: f64): f64 = a ^ b;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@square").unwrap().llvm_represent(),
+        "define i64 @square(i64 %x) {
+  %1 = mul i64 %x, %x
+  ret i64 %1
+}"
+    );
+    // x * (x * x) ^ 2
+    assert_eq!(
+        module.functions.get("@pow5").unwrap().llvm_represent(),
+        "define i64 @pow5(i64 %x) {
+  %1 = mul i64 %x, %x
+  %2 = mul i64 %1, %1
+  %3 = mul i64 %x, %2
+  ret i64 %3
+}"
+    );
+    assert_eq!(
+        module.functions.get("@fpow").unwrap().llvm_represent(),
+        "define double @fpow(double %a, double %b) {
+  %1 = call double @llvm.pow.f64(double %a, double %b)
+  ret double %1
+}"
     );
     assert_eq!(
         module
             .functions
-            .get("@\"Foo::bar\"")
+            .get("@llvm.pow.f64")
             .unwrap()
             .llvm_represent(),
-        "define void @\"Foo::bar\"(%Foo* %self) {
-  ret void
-}"
+        "declare double @llvm.pow.f64(double %0, double %1)"
     );
 }
 
 #[test]
-fn llvm_if_else() {
+fn unary_plus() {
     let code = "
-    foo(): void {
-      if true {
-        return;
-      } else if true {
-      } else {
-      }
-    }
+    x: int = +1;
+    foo(a: int): int = 1 + +a;
     ";
     let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 1");
     assert_eq!(
         module.functions.get("@foo").unwrap().llvm_represent(),
-        "define void @foo() {
-  br i1 true, label %1, label %2
-; <label>:1:
-  ret void
-; <label>:2:
-  br i1 true, label %3, label %4
-; <label>:3:
-  br label %5
-; <label>:4:
-  br label %5
-; <label>:5:
-  ret void
+        "define i64 @foo(i64 %a) {
+  %1 = add i64 1, %a
+  ret i64 %1
 }"
     )
 }
 
+#[test]
+fn unary_minus() {
+    let code = "
+    x: int = -(1 + 2);
+    neg(a: int): int {
+      return -a;
+    }
+    sub(a: int, b: int): int = -a + b;
+    negf(a: f64): f64 = -a;
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i64 -3");
+    assert_eq!(
+        module.functions.get("@neg").unwrap().llvm_represent(),
+        "define i64 @neg(i64 %a) {
+  %1 = sub i64 0, %a
+  ret i64 %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@sub").unwrap().llvm_represent(),
+        "define i64 @sub(i64 %a, i64 %b) {
+  %1 = sub i64 0, %a
+  %2 = add i64 %1, %b
+  ret i64 %2
+}"
+    );
+    assert_eq!(
+        module.functions.get("@negf").unwrap().llvm_represent(),
+        "define double @negf(double %a) {
+  %1 = fneg double %a
+  ret double %1
+}"
+    );
+}
+
+#[test]
+fn unary_not() {
+    let code = "
+    x: bool = !true;
+    yes(): bool = !false;
+    not(a: bool): bool = !a;
+    ";
+    let module = gen_code(code);
+    assert_eq!(module.variables[0].llvm_represent(), "@x = global i1 false");
+    assert_eq!(
+        module.functions.get("@yes").unwrap().llvm_represent(),
+        "define i1 @yes() {
+  ret i1 true
+}"
+    );
+    assert_eq!(
+        module.functions.get("@not").unwrap().llvm_represent(),
+        "define i1 @not(i1 %a) {
+  %1 = xor i1 %a, true
+  ret i1 %1
+}"
+    );
+}
+
+#[test]
+fn cast_between_numbers() {
+    let code = "
+    one(): i32 = 1 as i32;
+    widen(x: i32): int = x as int;
+    to_f32(x: i32): f32 = x as f32;
+    truncate(x: f64): int = x as int;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@one").unwrap().llvm_represent(),
+        "define i32 @one() {
+  ret i32 1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@widen").unwrap().llvm_represent(),
+        "define i64 @widen(i32 %x) {
+  %1 = sext i32 %x to i64
+  ret i64 %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@to_f32").unwrap().llvm_represent(),
+        "define float @to_f32(i32 %x) {
+  %1 = sitofp i32 %x to float
+  ret float %1
+}"
+    );
+    assert_eq!(
+        module.functions.get("@truncate").unwrap().llvm_represent(),
+        "define i64 @truncate(double %x) {
+  %1 = fptosi double %x to i64
+  ret i64 %1
+}"
+    );
+}
+
+#[test]
+fn number_literal_with_suffix() {
+    let code = "
+    byte(): i8 = -5'i8;
+    word(): i32 = 0xFF_FF'i32;
+    big(): f64 = 1_000.5e3'f64;
+    min: i8 = -128'i8;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@byte").unwrap().llvm_represent(),
+        "define i8 @byte() {
+  ret i8 -5
+}"
+    );
+    assert_eq!(
+        module.variables[0].llvm_represent(),
+        "@min = global i8 -128"
+    );
+    assert_eq!(
+        module.functions.get("@word").unwrap().llvm_represent(),
+        "define i32 @word() {
+  ret i32 65535
+}"
+    );
+    assert_eq!(
+        module.functions.get("@big").unwrap().llvm_represent(),
+        "define double @big() {
+  ret double 0x412E886800000000
+}"
+    );
+}
+
+#[test]
+fn global_constant_cast() {
+    let code = "
+    x: f64 = 1 as f64;
+    y: f32 = 1.5'f32;
+    z: i16 = 70000 as i16;
+    w: i32 = 3'i32 + 4'i32;
+    c: char = 65 as char;
+    n: i8 = -(100'i8 + 100'i8);
+    ";
+    let module = gen_code(code);
+    let variables: Vec<String> = module
+        .variables
+        .iter()
+        .map(|v| v.llvm_represent())
+        .collect();
+    assert_eq!(
+        variables,
+        vec![
+            "@x = global double 0x3FF0000000000000",
+            "@y = global float 0x3FF8000000000000",
+            "@z = global i16 4464",
+            "@w = global i32 7",
+            "@c = global i32 65",
+            "@n = global i8 56",
+        ]
+    );
+}
+
+#[test]
+fn match_lowered_to_switch() {
+    let code = "
+    classify(x: i32): int = match x { 1 => 10, 2 => 20, _ => 0 };
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@classify").unwrap().llvm_represent(),
+        "define i64 @classify(i32 %x) {
+  switch i32 %x, label %3 [ i32 1, label %1 i32 2, label %2 ]
+; <label>:1:
+  br label %4
+; <label>:2:
+  br label %4
+; <label>:3:
+  br label %4
+; <label>:4:
+  %5 = phi i64 [ 10, %1 ], [ 20, %2 ], [ 0, %3 ]
+  ret i64 %5
+}"
+    );
+}
+
+#[test]
+fn match_arms_are_coerced_to_expected_type() {
+    let code = "
+    ratio(x: int): f64 = match x { 1 => 2, _ => 0.5 };
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@ratio").unwrap().llvm_represent(),
+        "define double @ratio(i64 %x) {
+  switch i64 %x, label %2 [ i64 1, label %1 ]
+; <label>:1:
+  br label %3
+; <label>:2:
+  br label %3
+; <label>:3:
+  %4 = phi double [ 0x4000000000000000, %1 ], [ 0x3FE0000000000000, %2 ]
+  ret double %4
+}"
+    );
+}
+
+#[test]
+fn pattern_out_of_range_is_reported() {
+    let code = "
+    classify(x: i32): int = match x { 4294967296 => 1, _ => 0 };
+    ";
+    let module = gen_code(code);
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(
+        errors,
+        vec!["pattern doesn't fit the type of matched value"]
+    );
+}
+
+#[test]
+fn inner_variable_shadows_outer_one() {
+    let code = "
+    shadow(x: int): int {
+      y: int = x + 1;
+      if x > 0 {
+        y: int = x * 2;
+        return y;
+      }
+      return y;
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@shadow").unwrap().llvm_represent(),
+        "define i64 @shadow(i64 %x) {
+  %1 = add i64 %x, 1
+  %2 = icmp sgt i64 %x, 0
+  br i1 %2, label %3, label %5
+; <label>:3:
+  %4 = mul i64 %x, 2
+  ret i64 %4
+; <label>:5:
+  br label %6
+; <label>:6:
+  ret i64 %1
+}"
+    );
+}
+
+#[test]
+fn mutable_variable_can_be_assigned() {
+    let code = "
+    two(): int {
+      mut x: int = 1;
+      x = 2;
+      return x;
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@two").unwrap().llvm_represent(),
+        "define i64 @two() {
+  %1 = alloca i64
+  store i64 1, i64* %1
+  store i64 2, i64* %1
+  %2 = load i64, i64* %1
+  ret i64 %2
+}"
+    );
+}
+
+#[test]
+fn recursive_factorial() {
+    let code = "
+    factorial(n: int): int {
+      if n <= 1 {
+        return 1;
+      }
+      return n * factorial(n - 1);
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@factorial").unwrap().llvm_represent(),
+        "define i64 @factorial(i64 %n) {
+  %1 = icmp sle i64 %n, 1
+  br i1 %1, label %2, label %3
+; <label>:2:
+  ret i64 1
+; <label>:3:
+  br label %4
+; <label>:4:
+  %5 = sub i64 %n, 1
+  %6 = call i64 @factorial(i64 %5)
+  %7 = mul i64 %n, %6
+  ret i64 %7
+}"
+    );
+}
+
+#[test]
+fn size_of_types() {
+    use ir::{Field, Type};
+    let point = Type::Struct {
+        name: "Point".to_string(),
+        fields: vec![Field {
+            name: "x".to_string(),
+            typ: Type::Int(64).into(),
+        }],
+    };
+    let typ = Type::Struct {
+        name: "Foo".to_string(),
+        fields: vec![
+            Field {
+                name: "a".to_string(),
+                typ: Type::Int(64).into(),
+            },
+            Field {
+                name: "b".to_string(),
+                typ: Type::Float(32).into(),
+            },
+            Field {
+                name: "c".to_string(),
+                typ: Type::Pointer(Type::Int(8).into()).into(),
+            },
+            // a class field is a pointer to the struct
+            Field {
+                name: "d".to_string(),
+                typ: point.into(),
+            },
+            Field {
+                name: "e".to_string(),
+                typ: Type::Array {
+                    len: 3,
+                    element_type: Type::Float(64).into(),
+                }
+                .into(),
+            },
+        ],
+    };
+    assert_eq!(typ.size(), 64);
+    // `b` is padded to 64 bits since `c` is aligned to 64 bits
+    assert_eq!(typ.struct_size(), 64 + 64 + 64 + 64 + 3 * 64);
+}
+
+#[test]
+fn struct_size_is_padded_by_alignment() {
+    use ir::{Field, Type};
+    let field = |name: &str, typ: Type| Field {
+        name: name.to_string(),
+        typ: typ.into(),
+    };
+    let typ = Type::Struct {
+        name: "Foo".to_string(),
+        fields: vec![field("a", Type::Int(8)), field("b", Type::Int(32))],
+    };
+    let naive_size: usize = 8 + 32;
+    assert_eq!(typ.struct_size(), 64);
+    assert_ne!(typ.struct_size(), naive_size);
+    // tail padding to the alignment of `i32`
+    let typ = Type::Struct {
+        name: "Bar".to_string(),
+        fields: vec![
+            field("a", Type::Int(32)),
+            field("b", Type::Int(1)),
+            field("c", Type::Int(8)),
+        ],
+    };
+    assert_eq!(typ.struct_size(), 64);
+}
+
+#[test]
+fn test_class_define() {
+    let code = "
+    class Foo {
+      x: int;
+      ::new(): Foo;
+      bar(): void {}
+    }";
+    let module = gen_code(code);
+    assert_eq!(
+        module.types.get("Foo").unwrap().llvm_def(),
+        "%Foo = type { i64 }"
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Foo::new\"")
+            .unwrap()
+            .llvm_represent(),
+        "declare %Foo* @\"Foo::new\"()"
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Foo::bar\"")
+            .unwrap()
+            .llvm_represent(),
+        "define void @\"Foo::bar\"(%Foo* %self) {
+  ret void
+}"
+    );
+}
+
+#[test]
+fn class_construction() {
+    let code = "
+    class Point {
+      x: int;
+      y: f64;
+      ::new(x: int): Point = Point {y: 2, x: x};
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Point::new\"")
+            .unwrap()
+            .llvm_represent(),
+        format!(
+            "define %Point* @\"Point::new\"(i64 %x) {{
+  %1 = call i8* @malloc(i64 16)
+  %2 = bitcast i8* %1 to %Point*
+  %3 = getelementptr %Point, %Point* %2, i32 0, i32 0
+  store i64 %x, i64* %3
+  %4 = getelementptr %Point, %Point* %2, i32 0, i32 1
+  store double 0x{:016X}, double* %4
+  ret %Point* %2
+}}",
+            2f64.to_bits()
+        )
+    );
+}
+
+#[test]
+fn method_field_shorthand() {
+    let code = "
+    class Foo {
+      x: int;
+      get_x(): int = x;
+      get_self_x(): int = self.x;
+    }";
+    let module = gen_code(code);
+    let body = "(%Foo* %self) {
+  %1 = getelementptr %Foo, %Foo* %self, i32 0, i32 0
+  %2 = load i64, i64* %1
+  ret i64 %2
+}";
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Foo::get_x\"")
+            .unwrap()
+            .llvm_represent(),
+        format!("define i64 @\"Foo::get_x\"{}", body)
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Foo::get_self_x\"")
+            .unwrap()
+            .llvm_represent(),
+        format!("define i64 @\"Foo::get_self_x\"{}", body)
+    );
+}
+
+#[test]
+fn method_call() {
+    let code = "
+    class Rect {
+      w: int;
+      h: int;
+      area(): int = w * h;
+      scale(n: int): int = self.area() * n;
+    }
+    double_area(r: Rect): int = r.scale(2);
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Rect::scale\"")
+            .unwrap()
+            .llvm_represent(),
+        "define i64 @\"Rect::scale\"(%Rect* %self, i64 %n) {
+  %1 = call i64 @\"Rect::area\"(%Rect* %self)
+  %2 = mul i64 %1, %n
+  ret i64 %2
+}"
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@double_area")
+            .unwrap()
+            .llvm_represent(),
+        "define i64 @double_area(%Rect* %r) {
+  %1 = call i64 @\"Rect::scale\"(%Rect* %r, i64 2)
+  ret i64 %1
+}"
+    );
+}
+
+#[test]
+fn trait_method_dispatch() {
+    let code = "
+    trait Shape {
+      area(): int;
+    }
+    class Square <: Shape {
+      side: int;
+      area(): int = side * side;
+    }
+    class Rect <: Shape {
+      w: int;
+      h: int;
+      area(): int = w * h;
+    }
+    area(s: Shape): int = s.area();
+    square_area(s: Square): int = area(s);
+    rect_area(r: Rect): int = area(r);
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.types.get("Shape").unwrap().llvm_def(),
+        "%Shape = type { i8*, %\"Shape.vtable\"* }"
+    );
+    assert_eq!(
+        module.types.get("\"Shape.vtable\"").unwrap().llvm_def(),
+        "%\"Shape.vtable\" = type { i64 (i8*)* }"
+    );
+    let vtables: Vec<_> = module
+        .variables
+        .iter()
+        .map(|v| v.llvm_represent())
+        .filter(|v| v.contains(" as Shape"))
+        .collect();
+    assert_eq!(
+        vtables,
+        vec![
+            "@\"Square as Shape\" = global %\"Shape.vtable\" { i64 (i8*)* bitcast (i64 (%Square*)* @\"Square::area\" to i64 (i8*)*) }",
+            "@\"Rect as Shape\" = global %\"Shape.vtable\" { i64 (i8*)* bitcast (i64 (%Rect*)* @\"Rect::area\" to i64 (i8*)*) }",
+        ]
+    );
+    assert_eq!(
+        module.functions.get("@area").unwrap().llvm_represent(),
+        "define i64 @area(%Shape* %s) {
+  %1 = getelementptr %Shape, %Shape* %s, i32 0, i32 1
+  %2 = load %\"Shape.vtable\"*, %\"Shape.vtable\"** %1
+  %3 = getelementptr %\"Shape.vtable\", %\"Shape.vtable\"* %2, i32 0, i32 0
+  %4 = load i64 (i8*)*, i64 (i8*)** %3
+  %5 = getelementptr %Shape, %Shape* %s, i32 0, i32 0
+  %6 = load i8*, i8** %5
+  %7 = call i64 %4(i8* %6)
+  ret i64 %7
+}"
+    );
+    assert_eq!(
+        module.functions.get("@rect_area").unwrap().llvm_represent(),
+        "define i64 @rect_area(%Rect* %r) {
+  %1 = call i8* @malloc(i64 16)
+  %2 = bitcast i8* %1 to %Shape*
+  %3 = bitcast %Rect* %r to i8*
+  %4 = getelementptr %Shape, %Shape* %2, i32 0, i32 0
+  store i8* %3, i8** %4
+  %5 = getelementptr %Shape, %Shape* %2, i32 0, i32 1
+  store %\"Shape.vtable\"* @\"Rect as Shape\", %\"Shape.vtable\"** %5
+  %6 = call i64 @area(%Shape* %2)
+  ret i64 %6
+}"
+    );
+}
+
+#[test]
+fn class_value_stored_into_trait_field_is_upcast() {
+    let code = "
+    trait Shape {
+      area(): int;
+    }
+    class Square <: Shape {
+      side: int;
+      area(): int = side * side;
+    }
+    class Holder {
+      shape: Shape;
+      ::new(s: Square): Holder = Holder { shape: s };
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module
+            .functions
+            .get("@\"Holder::new\"")
+            .unwrap()
+            .llvm_represent(),
+        "define %Holder* @\"Holder::new\"(%Square* %s) {
+  %1 = call i8* @malloc(i64 8)
+  %2 = bitcast i8* %1 to %Holder*
+  %3 = getelementptr %Holder, %Holder* %2, i32 0, i32 0
+  %4 = call i8* @malloc(i64 16)
+  %5 = bitcast i8* %4 to %Shape*
+  %6 = bitcast %Square* %s to i8*
+  %7 = getelementptr %Shape, %Shape* %5, i32 0, i32 0
+  store i8* %6, i8** %7
+  %8 = getelementptr %Shape, %Shape* %5, i32 0, i32 1
+  store %\"Shape.vtable\"* @\"Square as Shape\", %\"Shape.vtable\"** %8
+  store %Shape* %5, %Shape** %3
+  ret %Holder* %2
+}"
+    );
+}
+
+#[test]
+fn each_upcast_allocates_a_trait_object() {
+    // a trait object is never freed yet, so converting the same value twice allocates twice
+    let code = "
+    trait Shape {
+      area(): int;
+    }
+    class Square <: Shape {
+      side: int;
+      area(): int = side * side;
+    }
+    area(s: Shape): int = s.area();
+    twice(s: Square): int = area(s) + area(s);
+    ";
+    let module = gen_code(code);
+    let twice = module.functions.get("@twice").unwrap().llvm_represent();
+    assert_eq!(twice.matches("call i8* @malloc(i64 16)").count(), 2);
+}
+
+#[test]
+fn missing_trait_method_is_reported() {
+    let code = "
+    trait Shape {
+      area(): int;
+    }
+    class Square <: Shape {
+      side: int;
+    }
+    area(s: Shape): int = s.area();
+    square_area(s: Square): int = area(s);
+    ";
+    let mut parser = crate::parser::Parser::new("", code);
+    let program = parser.parse_top_list(EOF).unwrap();
+    let module = CodeGenerator::new().generate_module("test", &program);
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(
+        errors,
+        vec!["class `Square` doesn't implement method `area` of trait `Shape`"]
+    );
+}
+
+#[test]
+fn list_literal_and_index() {
+    let code = "
+    second(): int = [1, 2, 3][1];
+    get(xs: List[f64], i: int): f64 = xs[i];
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@second").unwrap().llvm_represent(),
+        "define i64 @second() {
+  %1 = call i8* @malloc(i64 24)
+  %2 = bitcast i8* %1 to i64*
+  %3 = getelementptr i64, i64* %2, i32 0
+  store i64 1, i64* %3
+  %4 = getelementptr i64, i64* %2, i32 1
+  store i64 2, i64* %4
+  %5 = getelementptr i64, i64* %2, i32 2
+  store i64 3, i64* %5
+  %6 = getelementptr i64, i64* %2, i64 1
+  %7 = load i64, i64* %6
+  ret i64 %7
+}"
+    );
+    assert_eq!(
+        module.functions.get("@get").unwrap().llvm_represent(),
+        "define double @get(double* %xs, i64 %i) {
+  %1 = getelementptr double, double* %xs, i64 %i
+  %2 = load double, double* %1
+  ret double %2
+}"
+    );
+}
+
+#[test]
+fn constant_index_out_of_bounds_is_reported() {
+    let code = "
+    fourth(): int {
+      xs: List[int] = [1, 2, 3];
+      return xs[3];
+    }
+    last(xs: List[int]): int = xs[-1];
+    ";
+    let mut parser = crate::parser::Parser::new("", code);
+    let program = parser.parse_top_list(EOF).unwrap();
+    let module = CodeGenerator::new().generate_module("test", &program);
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(
+        errors,
+        vec![
+            "index 3 out of bounds for list of length 3",
+            "index -1 out of bounds, index of list cannot be negative",
+        ]
+    );
+}
+
+#[test]
+fn return_and_destructure_tuple() {
+    let code = "
+    div_mod(a: int, b: int): (int, int) = (a / b, a % b);
+    sum(): int {
+      (q, r): (int, int) = div_mod(7, 2);
+      return q + r;
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@div_mod").unwrap().llvm_represent(),
+        "define { i64, i64 } @div_mod(i64 %a, i64 %b) {
+  %1 = sdiv i64 %a, %b
+  %2 = srem i64 %a, %b
+  %3 = insertvalue { i64, i64 } undef, i64 %1, 0
+  %4 = insertvalue { i64, i64 } %3, i64 %2, 1
+  ret { i64, i64 } %4
+}"
+    );
+    assert_eq!(
+        module.functions.get("@sum").unwrap().llvm_represent(),
+        "define i64 @sum() {
+  %1 = call { i64, i64 } @div_mod(i64 7, i64 2)
+  %2 = extractvalue { i64, i64 } %1, 0
+  %3 = extractvalue { i64, i64 } %1, 1
+  %4 = add i64 %2, %3
+  ret i64 %4
+}"
+    );
+}
+
+#[test]
+fn entry_point_returns_exit_code() {
+    let code = "
+    main(): void {}
+    ";
+    let mut module = gen_code(code);
+    assert_eq!(module.set_entry_point(), true);
+    assert_eq!(
+        module.functions.get("@main").unwrap().llvm_represent(),
+        "define i32 @main() {
+  call void @\"elz.main\"()
+  ret i32 0
+}"
+    );
+    assert_eq!(
+        module
+            .functions
+            .get("@\"elz.main\"")
+            .unwrap()
+            .llvm_represent(),
+        "define void @\"elz.main\"() {
+  ret void
+}"
+    );
+    let mut module = gen_code("main(): int = 42;");
+    assert_eq!(module.set_entry_point(), true);
+    assert_eq!(
+        module.functions.get("@main").unwrap().llvm_represent(),
+        "define i32 @main() {
+  %1 = call i64 @\"elz.main\"()
+  %2 = trunc i64 %1 to i32
+  ret i32 %2
+}"
+    );
+    let mut module = gen_code("foo(): void {}");
+    assert_eq!(module.set_entry_point(), false);
+}
+
+#[test]
+fn logical_operator_short_circuits() {
+    let code = "
+    check(): bool = true;
+    both(a: bool): bool = a && check();
+    either(a: bool): bool = a || check();
+    ";
+    let module = gen_code(code);
+    // `check()` is only called in the block `%1`
+    assert_eq!(
+        module.functions.get("@both").unwrap().llvm_represent(),
+        "define i1 @both(i1 %a) {
+  br i1 %a, label %1, label %3
+; <label>:1:
+  %2 = call i1 @check()
+  br label %3
+; <label>:3:
+  %4 = phi i1 [ false, %0 ], [ %2, %1 ]
+  ret i1 %4
+}"
+    );
+    assert_eq!(
+        module.functions.get("@either").unwrap().llvm_represent(),
+        "define i1 @either(i1 %a) {
+  br i1 %a, label %3, label %1
+; <label>:1:
+  %2 = call i1 @check()
+  br label %3
+; <label>:3:
+  %4 = phi i1 [ true, %0 ], [ %2, %1 ]
+  ret i1 %4
+}"
+    );
+}
+
+#[test]
+fn llvm_if_else() {
+    let code = "
+    foo(): void {
+      if true {
+        return;
+      } else if true {
+      } else {
+      }
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@foo").unwrap().llvm_represent(),
+        "define void @foo() {
+  br i1 true, label %1, label %2
+; <label>:1:
+  ret void
+; <label>:2:
+  br i1 true, label %3, label %4
+; <label>:3:
+  br label %5
+; <label>:4:
+  br label %5
+; <label>:5:
+  ret void
+}"
+    )
+}
+
+#[test]
+fn llvm_if_else_both_return() {
+    let code = "
+    f(): int {
+      if 1 == 1 {
+        return 3;
+      } else {
+        return 4;
+      }
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@f").unwrap().llvm_represent(),
+        "define i64 @f() {
+  %1 = icmp eq i64 1, 1
+  br i1 %1, label %2, label %3
+; <label>:2:
+  ret i64 3
+; <label>:3:
+  ret i64 4
+}"
+    )
+}
+
+#[test]
+fn llvm_loop_with_break() {
+    let code = "
+    done(): bool = true;
+    foo(): void {
+      loop {
+        println(\"tick\");
+        if done() {
+          break;
+        }
+      }
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@foo").unwrap().llvm_represent(),
+        "define void @foo() {
+  br label %1
+; <label>:1:
+  %2 = getelementptr [5 x i8], [5 x i8]* @0, i32 0, i32 0
+  %3 = call %string* @\"string::new\"(i8* %2, i64 4)
+  call void @println(%string* %3)
+  %4 = call i1 @done()
+  br i1 %4, label %5, label %6
+; <label>:5:
+  br label %8
+; <label>:6:
+  br label %7
+; <label>:7:
+  br label %1
+; <label>:8:
+  ret void
+}"
+    )
+}
+
+#[test]
+fn llvm_loop_with_continue() {
+    let code = "
+    skip(): bool = true;
+    foo(): void {
+      loop {
+        if skip() {
+          continue;
+        }
+        break;
+      }
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@foo").unwrap().llvm_represent(),
+        "define void @foo() {
+  br label %1
+; <label>:1:
+  %2 = call i1 @skip()
+  br i1 %2, label %3, label %4
+; <label>:3:
+  br label %1
+; <label>:4:
+  br label %5
+; <label>:5:
+  br label %6
+; <label>:6:
+  ret void
+}"
+    )
+}
+
+#[test]
+fn llvm_while() {
+    let code = "
+    next(): bool = true;
+    count(n: int): int {
+      while n > 0 {
+        if next() {
+          continue;
+        }
+        break;
+      }
+      return n;
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@count").unwrap().llvm_represent(),
+        "define i64 @count(i64 %n) {
+  br label %1
+; <label>:1:
+  %2 = icmp sgt i64 %n, 0
+  br i1 %2, label %3, label %8
+; <label>:3:
+  %4 = call i1 @next()
+  br i1 %4, label %5, label %6
+; <label>:5:
+  br label %1
+; <label>:6:
+  br label %7
+; <label>:7:
+  br label %8
+; <label>:8:
+  ret i64 %n
+}"
+    )
+}
+
+#[test]
+fn string_literal_is_null_terminated_array() {
+    let code = "
+    main(): void {
+      println(\"ab\");
+    }
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.variables[0].llvm_represent(),
+        "@0 = private unnamed_addr constant [3 x i8] c\"ab\\00\""
+    );
+    assert_eq!(
+        module.functions.get("@main").unwrap().llvm_represent(),
+        "define void @main() {
+  %1 = getelementptr [3 x i8], [3 x i8]* @0, i32 0, i32 0
+  %2 = call %string* @\"string::new\"(i8* %1, i64 2)
+  call void @println(%string* %2)
+  ret void
+}"
+    );
+}
+
+#[test]
+fn identical_string_literals_share_global() {
+    let code = "
+    main(): void {
+      println(\"hello\");
+      println(\"hello\");
+      println(\"world\");
+    }
+    ";
+    let module = gen_code(code);
+    let string_globals: Vec<_> = module
+        .variables
+        .iter()
+        .map(|v| v.llvm_represent())
+        .collect();
+    assert_eq!(
+        string_globals,
+        vec![
+            "@0 = private unnamed_addr constant [6 x i8] c\"hello\\00\"",
+            "@1 = private unnamed_addr constant [6 x i8] c\"world\\00\"",
+        ]
+    );
+}
+
+#[test]
+fn module_header_use_module_name() {
+    let module = gen_code("");
+    assert_eq!(
+        module.llvm_represent().lines().take(2).collect::<Vec<_>>(),
+        vec!["; ModuleID = 'test'", "source_filename = \"test\""]
+    );
+}
+
+#[test]
+fn provenance_comment() {
+    let code = "x: int = 1;\nmain(): void {}";
+    let program = crate::parser::Parser::new("main.elz", code)
+        .parse_top_list(EOF)
+        .unwrap();
+    let module = CodeGenerator::new().generate_module("main", &program);
+    assert_eq!(module.llvm_represent().contains("; main.elz"), false);
+
+    let module = CodeGenerator::new()
+        .with_provenance()
+        .generate_module("main", &program);
+    assert_eq!(
+        module.llvm_represent(),
+        "; ModuleID = 'main'
+source_filename = \"main\"
+; main.elz:1:0
+@x = global i64 1
+; main.elz:2:0
+define void @main() {
+  ret void
+}
+"
+    );
+}
+
+#[test]
+fn debug_info_line_tables() {
+    let code = "add(x: int, y: int): int {\n  z: int = 1;\n  return x + y;\n}";
+    let program = crate::parser::Parser::new("main.elz", code)
+        .parse_top_list(EOF)
+        .unwrap();
+    let module = CodeGenerator::new().generate_module("main.elz", &program);
+    assert_eq!(module.llvm_represent().contains("!dbg"), false);
+
+    let module = CodeGenerator::new()
+        .with_debug_info()
+        .generate_module("main.elz", &program);
+    assert_eq!(
+        module.llvm_represent(),
+        "; ModuleID = 'main.elz'
+source_filename = \"main.elz\"
+define i64 @add(i64 %x, i64 %y) !dbg !5 {
+  %1 = add i64 %x, %y, !dbg !6
+  ret i64 %1, !dbg !6
+}
+!llvm.dbg.cu = !{!1}
+!llvm.module.flags = !{!3, !4}
+!0 = !DIFile(filename: \"main.elz\", directory: \"\")
+!1 = distinct !DICompileUnit(language: DW_LANG_C, file: !0, producer: \"elz\", isOptimized: false, runtimeVersion: 0, emissionKind: LineTablesOnly)
+!2 = !DISubroutineType(types: !{})
+!3 = !{i32 2, !\"Dwarf Version\", i32 4}
+!4 = !{i32 2, !\"Debug Info Version\", i32 3}
+!5 = distinct !DISubprogram(name: \"add\", scope: !0, file: !0, line: 1, type: !2, scopeLine: 1, spFlags: DISPFlagDefinition, unit: !1)
+!6 = !DILocation(line: 3, column: 3, scope: !5)
+"
+    );
+}
+
+#[test]
+fn remainder_operator() {
+    let code = "
+    m(): int {
+      return 10 % 3;
+    }
+    x: f64 = 5.5 % 2.0;
+    irem(a: int, b: int): int = a + a % b;
+    frem(a: f64, b: f64): f64 = a % b;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@m").unwrap().llvm_represent(),
+        "define i64 @m() {
+  %1 = srem i64 10, 3
+  ret i64 %1
+}"
+    );
+    assert_eq!(
+        module.variables[0].llvm_represent(),
+        format!("@x = global double 0x{:016X}", 1.5f64.to_bits())
+    );
+    assert_eq!(
+        module.functions.get("@irem").unwrap().llvm_represent(),
+        "define i64 @irem(i64 %a, i64 %b) {
+  %1 = srem i64 %a, %b
+  %2 = add i64 %a, %1
+  ret i64 %2
+}"
+    );
+    assert_eq!(
+        module.functions.get("@frem").unwrap().llvm_represent(),
+        "define double @frem(double %a, double %b) {
+  %1 = frem double %a, %b
+  ret double %1
+}"
+    );
+}
+
+#[test]
+fn pow_operator() {
+    let code = "
+    p(): int {
+      return 2 ^ 10;
+    }
+    ipow(a: int, b: int): int = a ^ b;
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@p").unwrap().llvm_represent(),
+        "define i64 @p() {
+  ret i64 1024
+}"
+    );
+    assert_eq!(
+        module.functions.get("@ipow").unwrap().llvm_represent(),
+        "define i64 @ipow(i64 %a, i64 %b) {
+  %1 = call i64 @_pow_int(i64 %a, i64 %b)
+  ret i64 %1
+}"
+    );
+    assert_eq!(module.errors.is_empty(), true);
+}
+
+#[test]
+fn pow_without_prelude_is_reported() {
+    let code = "ipow(a: int, b: int): int = a ^ b;";
+    let mut parser = crate::parser::Parser::new("", code);
+    let program = parser.parse_top_list(EOF).unwrap();
+    let module = CodeGenerator::new().generate_module("test", &program);
+    assert_eq!(
+        module.functions.get("@ipow").unwrap().llvm_represent(),
+        "define i64 @ipow(i64 %a, i64 %b) {
+  ret i64 undef
+}"
+    );
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(
+        errors,
+        vec!["unsupported operator `^` on non-constant operands"]
+    );
+}
+
+#[test]
+fn print_by_printf() {
+    let code = "main(): void { print(\"hi\"); }";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@main").unwrap().llvm_represent(),
+        "define void @main() {
+  %1 = getelementptr [3 x i8], [3 x i8]* @0, i32 0, i32 0
+  %2 = call %string* @\"string::new\"(i8* %1, i64 2)
+  %3 = getelementptr %string, %string* %2, i32 0, i32 0
+  %4 = load i8*, i8** %3
+  %5 = getelementptr [3 x i8], [3 x i8]* @1, i32 0, i32 0
+  %6 = call i32 (i8*, ...) @printf(i8* %5, i8* %4)
+  ret void
+}"
+    );
+    assert_eq!(
+        module.functions.get("@printf").unwrap().llvm_represent(),
+        "declare i32 @printf(i8* %format, ...)"
+    );
+    let variables: Vec<_> = module
+        .variables
+        .iter()
+        .map(|v| v.llvm_represent())
+        .collect();
+    assert_eq!(
+        variables,
+        vec![
+            "@0 = private unnamed_addr constant [3 x i8] c\"hi\\00\"",
+            "@1 = private unnamed_addr constant [3 x i8] c\"%s\\00\""
+        ]
+    );
+}
+
+#[test]
+fn list_repeat_evaluates_element_once() {
+    let code = "
+    one(): int = 1;
+    f(): List[int] = [one(); 3];
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@f").unwrap().llvm_represent(),
+        "define i64* @f() {
+  %1 = call i64 @one()
+  %2 = call i8* @malloc(i64 24)
+  %3 = bitcast i8* %2 to i64*
+  br label %4
+; <label>:4:
+  %5 = phi i64 [ 0, %0 ], [ %9, %7 ]
+  %6 = icmp eq i64 %5, 3
+  br i1 %6, label %10, label %7
+; <label>:7:
+  %8 = getelementptr i64, i64* %3, i64 %5
+  store i64 %1, i64* %8
+  %9 = add i64 %5, 1
+  br label %4
+; <label>:10:
+  ret i64* %3
+}"
+    );
+}
+
+#[test]
+fn empty_list_takes_element_type_from_context() {
+    let code = "
+    sum(xs: List[f64]): f64 = 0.0;
+    empty(): List[i8] = [];
+    main(): void {
+      xs: List[int] = [];
+      sum([]);
+    }
+    ";
+    let module = gen_code(code);
+    let errors: Vec<_> = module.errors.iter().map(|err| err.message()).collect();
+    assert_eq!(errors, Vec::<String>::new());
+    assert_eq!(
+        module.functions.get("@empty").unwrap().llvm_represent(),
+        "define i8* @empty() {
+  %1 = call i8* @malloc(i64 0)
+  %2 = bitcast i8* %1 to i8*
+  ret i8* %2
+}"
+    );
+    assert_eq!(
+        module.functions.get("@main").unwrap().llvm_represent(),
+        "define void @main() {
+  %1 = call i8* @malloc(i64 0)
+  %2 = bitcast i8* %1 to i64*
+  %3 = call i8* @malloc(i64 0)
+  %4 = bitcast i8* %3 to double*
+  %5 = call double @sum(double* %4)
+  ret void
+}"
+    );
+}
+
+#[test]
+fn global_constant_list() {
+    let code = "
+    xs: List[int] = [];
+    ys: List[f64] = [0.0; 4294967295];
+    zs: List[int] = [7; 2];
+    first(): f64 = ys[0];
+    ";
+    let module = gen_code(code);
+    assert_eq!(
+        module.functions.get("@first").unwrap().llvm_represent(),
+        "define double @first() {
+  %1 = load double*, double** @ys
+  %2 = getelementptr double, double* %1, i64 0
+  %3 = load double, double* %2
+  ret double %3
+}"
+    );
+    let variables: Vec<String> = module
+        .variables
+        .iter()
+        .map(|v| v.llvm_represent())
+        .collect();
+    assert_eq!(
+        variables,
+        vec![
+            "@\"xs.data\" = global [0 x i64] []",
+            "@xs = global i64* bitcast ([0 x i64]* @\"xs.data\" to i64*)",
+            "@\"ys.data\" = global [4294967295 x double] zeroinitializer",
+            "@ys = global double* bitcast ([4294967295 x double]* @\"ys.data\" to double*)",
+            "@\"zs.data\" = global [2 x i64] [i64 7, i64 7]",
+            "@zs = global i64* bitcast ([2 x i64]* @\"zs.data\" to i64*)",
+        ]
+    );
+}
+
 // helpers, must put tests before this line
 fn gen_code(code: &'static str) -> ir::Module {
     let mut parser = crate::parser::Parser::new("", code);
@@ -162,5 +1836,5 @@ fn gen_code(code: &'static str) -> ir::Module {
     let mut prelude = crate::parser::parse_prelude();
     prelude.top_list.append(&mut program);
     let code_generator = CodeGenerator::new();
-    code_generator.generate_module(&prelude.top_list)
+    code_generator.generate_module("test", &prelude.top_list)
 }
@@ -0,0 +1,160 @@
+use crate::ast::*;
+use std::cmp::Ordering;
+use std::mem::discriminant;
+
+/// Constant is a value known at compile time, semantic checker and codegen both fold with it, so
+/// they always agree on the value of a constant expression
+#[derive(Clone, Debug, PartialEq)]
+pub(crate) enum Constant {
+    Int(i64),
+    F64(f64),
+    Bool(bool),
+    Char(char),
+    String(String),
+}
+
+// only the same kind of constants are comparable, e.g. `1 < 'a'` has no order
+impl PartialOrd for Constant {
+    fn partial_cmp(&self, other: &Constant) -> Option<Ordering> {
+        use Constant::*;
+        match (self, other) {
+            (Int(l), Int(r)) => l.partial_cmp(r),
+            (F64(l), F64(r)) => l.partial_cmp(r),
+            (Bool(l), Bool(r)) => l.partial_cmp(r),
+            (Char(l), Char(r)) => l.partial_cmp(r),
+            (String(l), String(r)) => l.partial_cmp(r),
+            _ => None,
+        }
+    }
+}
+
+impl Constant {
+    /// is_zero tells if all bits of the constant are zero, e.g. `0`, `false`, but not `-0.0`
+    pub(crate) fn is_zero(&self) -> bool {
+        match self {
+            Constant::Int(i) => *i == 0,
+            Constant::F64(f) => f.to_bits() == 0,
+            Constant::Bool(b) => !b,
+            Constant::Char(c) => *c == '\0',
+            Constant::String(_) => false,
+        }
+    }
+}
+
+/// evaluate folds an expression only contains literals, returns `None` if it isn't a constant
+pub(crate) fn evaluate(expr: &Expr) -> Option<Constant> {
+    use ExprVariant::*;
+    let c = match &expr.value {
+        Int(i) => Constant::Int(*i),
+        F64(f) => Constant::F64(*f),
+        Bool(b) => Constant::Bool(*b),
+        Char(c) => Constant::Char(*c),
+        String(s) => Constant::String(s.clone()),
+        // e.g. `-5'i8` is `-5 as i8`
+        Cast(e, typ) => cast(evaluate(e)?, typ.name().as_str())?,
+        Unary(op, e) => unary(op, evaluate(e)?)?,
+        Binary(l, r, op) => binary(evaluate(l)?, evaluate(r)?, op)?,
+        _ => return None,
+    };
+    Some(c)
+}
+
+/// is_constant_list tells if `expr` is a list literal of constants, e.g. `[0; 4]`, a global can be
+/// initialized with it
+pub(crate) fn is_constant_list(expr: &Expr) -> bool {
+    match &expr.value {
+        ExprVariant::List(elements) => elements.iter().all(|e| evaluate(e).is_some()),
+        ExprVariant::ListRepeat(e, _) => evaluate(e).is_some(),
+        _ => false,
+    }
+}
+
+/// cast folds `c as typ`, an integer truncates and a float rounds as they do at runtime, e.g.
+/// `300 as i8` is `44`, `typ` is the name of a number type or `char`
+pub(crate) fn cast(c: Constant, typ: &str) -> Option<Constant> {
+    use Constant::*;
+    let i = match &c {
+        Int(i) => *i,
+        F64(f) => *f as i64,
+        Bool(b) => *b as i64,
+        Char(c) => *c as i64,
+        String(_) => return None,
+    };
+    let c = match (c, typ) {
+        (F64(f), "f64") => F64(f),
+        (F64(f), "f32") => F64(f as f32 as f64),
+        (_, "f64") => F64(i as f64),
+        (_, "f32") => F64(i as f32 as f64),
+        (_, "int") => Int(i),
+        (_, "i32") => Int(i as i32 as i64),
+        (_, "i16") => Int(i as i16 as i64),
+        (_, "i8") => Int(i as i8 as i64),
+        (_, "char") => Char(std::char::from_u32(i as u32)?),
+        _ => return None,
+    };
+    Some(c)
+}
+
+/// unary folds `op c`, returns `None` if `op` can't apply to `c`
+pub(crate) fn unary(op: &UnaryOperator, c: Constant) -> Option<Constant> {
+    let c = match (op, c) {
+        (UnaryOperator::Plus, c @ Constant::Int(_))
+        | (UnaryOperator::Plus, c @ Constant::F64(_)) => c,
+        (UnaryOperator::Minus, Constant::Int(i)) => Constant::Int(i.wrapping_neg()),
+        (UnaryOperator::Minus, Constant::F64(f)) => Constant::F64(-f),
+        (UnaryOperator::Not, Constant::Bool(b)) => Constant::Bool(!b),
+        _ => return None,
+    };
+    Some(c)
+}
+
+/// binary folds `l op r`, integer arithmetic wraps as it does at runtime, `None` is returned if
+/// the result is left to runtime, e.g. `1 / 0`, or the operands are not the same kind
+pub(crate) fn binary(l: Constant, r: Constant, op: &Operator) -> Option<Constant> {
+    use Constant::*;
+    let c = match (l, r, op) {
+        (Int(l), Int(r), Operator::Plus) => Int(l.wrapping_add(r)),
+        (F64(l), F64(r), Operator::Plus) => F64(l + r),
+        (Int(l), Int(r), Operator::Minus) => Int(l.wrapping_sub(r)),
+        (F64(l), F64(r), Operator::Minus) => F64(l - r),
+        (Int(l), Int(r), Operator::Multiply) => Int(l.wrapping_mul(r)),
+        (F64(l), F64(r), Operator::Multiply) => F64(l * r),
+        (Int(l), Int(r), Operator::Divide) => Int(l.checked_div(r)?),
+        (F64(l), F64(r), Operator::Divide) => F64(l / r),
+        (Int(l), Int(r), Operator::Remainder) => Int(l.checked_rem(r)?),
+        (F64(l), F64(r), Operator::Remainder) => F64(l % r),
+        (Int(l), Int(r), Operator::Pow) if r >= 0 => Int(pow_int(l, r as u64)),
+        (F64(l), F64(r), Operator::Pow) => F64(l.powf(r)),
+        (Bool(l), Bool(r), Operator::And) => Bool(l && r),
+        (Bool(l), Bool(r), Operator::Or) => Bool(l || r),
+        (l, r, op) if op.is_comparison() && discriminant(&l) == discriminant(&r) => {
+            Bool(match op {
+                Operator::Equal => l == r,
+                Operator::NotEqual => l != r,
+                Operator::LessThan => l < r,
+                Operator::LessThanOrEqual => l <= r,
+                Operator::GreaterThan => l > r,
+                Operator::GreaterThanOrEqual => l >= r,
+                op => unreachable!("`{}` is not a comparison", op),
+            })
+        }
+        _ => return None,
+    };
+    Some(c)
+}
+
+/// pow_int is `base ^ exp` by square-and-multiply over the whole `u64` exponent, it wraps as
+/// `_pow_int` of prelude does at runtime
+fn pow_int(base: i64, exp: u64) -> i64 {
+    let mut result: i64 = 1;
+    let mut square = base;
+    let mut exp = exp;
+    while exp > 0 {
+        if exp & 1 == 1 {
+            result = result.wrapping_mul(square);
+        }
+        square = square.wrapping_mul(square);
+        exp >>= 1;
+    }
+    result
+}
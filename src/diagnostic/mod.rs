@@ -1,19 +1,38 @@
 use crate::lexer::Location;
 use codespan::Files;
-use codespan_reporting::diagnostic::{Diagnostic, Label};
+use codespan_reporting::diagnostic::{Diagnostic, Label, Severity};
 use codespan_reporting::term::emit;
 use codespan_reporting::term::termcolor::{ColorChoice, StandardStream};
 
+const TAB_WIDTH: usize = 4;
+
 pub struct Reporter {
     files: Files<String>,
+    // location of each diagnostic is kept for reporting without source, see `messages`
+    diagnostics: Vec<(Location, Diagnostic)>,
+    // errors after the first `max_errors` errors are dropped
+    max_errors: Option<usize>,
+    truncated: bool,
 }
 
 impl Reporter {
     pub fn new() -> Reporter {
         Reporter {
             files: Files::new(),
+            diagnostics: vec![],
+            max_errors: None,
+            truncated: false,
         }
     }
+    /// set_max_errors keeps only the first `max_errors` errors, the rest are replaced by one
+    /// `too many errors` error, warnings are not limited. `0` means no limit, the same as a new
+    /// reporter, since a reporter keeps no error at all could only say there are too many
+    pub fn set_max_errors(&mut self, max_errors: usize) {
+        self.max_errors = match max_errors {
+            0 => None,
+            n => Some(n),
+        };
+    }
 
     pub(crate) fn for_file<T: Into<String>>(&mut self, file_name: T, source: T) -> FileID {
         FileID {
@@ -21,12 +40,112 @@ impl Reporter {
             diagnostics: vec![],
         }
     }
+
+    pub fn has_errors(&self) -> bool {
+        self.diagnostics
+            .iter()
+            .any(|(_, diagnostic)| is_error(diagnostic))
+    }
+    /// clear drops all stored files and diagnostics, so the same reporter can be reused by the
+    /// next compilation without reporting stale diagnostics again
+    pub fn clear(&mut self) {
+        self.files = Files::new();
+        self.diagnostics.clear();
+        self.truncated = false;
+    }
+    fn add(&mut self, location: Location, diagnostic: Diagnostic) {
+        if is_error(&diagnostic) {
+            if self.truncated {
+                return;
+            }
+            let errors = self
+                .diagnostics
+                .iter()
+                .filter(|(_, diagnostic)| is_error(diagnostic))
+                .count();
+            match self.max_errors {
+                Some(max_errors) if errors >= max_errors => {
+                    self.truncated = true;
+                    let file_id = diagnostic.primary_label.file_id;
+                    let diagnostic = Diagnostic::new_error(
+                        format!("too many errors, stopping after {} errors", max_errors),
+                        Label::new(file_id, 0..0, "too many errors"),
+                    );
+                    self.diagnostics.push((Location::none(), diagnostic));
+                    return;
+                }
+                _ => (),
+            }
+        }
+        self.diagnostics.push((location, diagnostic));
+    }
+    pub fn emit(&self) {
+        let writer = StandardStream::stderr(ColorChoice::Auto);
+        let config = codespan_reporting::term::Config::default();
+        for (_, diagnostic) in self.sorted() {
+            emit(&mut writer.lock(), &config, &self.files, &diagnostic).unwrap();
+        }
+    }
+    /// messages formats each diagnostic as `file:line:column: message` in one line, without
+    /// quoting the source
+    pub fn messages(&self) -> Vec<String> {
+        self.sorted()
+            .into_iter()
+            .map(|(location, diagnostic)| format!("{}: {}", location, diagnostic.message))
+            .collect()
+    }
+    /// messages_with_source is `messages` followed by the source line of the diagnostic and a `^`
+    /// under its column, tab is expanded to 4 spaces so the `^` still lines up
+    pub fn messages_with_source(&self) -> Vec<String> {
+        self.sorted()
+            .into_iter()
+            .map(|(location, diagnostic)| {
+                let message = format!("{}: {}", location, diagnostic.message);
+                let source: &str = self.files.source(diagnostic.primary_label.file_id).as_ref();
+                // line starts from 1, line 0 is a diagnostic without position and has no source
+                let line = match (location.line() as usize).checked_sub(1) {
+                    Some(index) => source.lines().nth(index),
+                    None => None,
+                };
+                match line {
+                    Some(line) => {
+                        let indent: usize = line
+                            .chars()
+                            .take(location.column() as usize)
+                            .map(|c| if c == '\t' { TAB_WIDTH } else { 1 })
+                            .sum();
+                        format!(
+                            "{}\n{}\n{}^",
+                            message,
+                            line.replace('\t', &" ".repeat(TAB_WIDTH)),
+                            " ".repeat(indent)
+                        )
+                    }
+                    None => message,
+                }
+            })
+            .collect()
+    }
+    /// sorted orders diagnostics by file and position, diagnostics without position(`Location::none`)
+    /// are put at the end, so the output not depends on the order of checking
+    fn sorted(&self) -> Vec<&(Location, Diagnostic)> {
+        let mut diagnostics: Vec<_> = self.diagnostics.iter().collect();
+        diagnostics.sort_by_key(|(location, _)| {
+            (
+                location.line() == 0,
+                location.file_name().to_string(),
+                location.line(),
+                location.column(),
+            )
+        });
+        diagnostics
+    }
 }
 
 #[derive(Clone)]
 pub(crate) struct FileID {
     value: codespan::FileId,
-    diagnostics: Vec<Diagnostic>,
+    diagnostics: Vec<(Location, Diagnostic)>,
 }
 
 impl FileID {
@@ -36,16 +155,32 @@ impl FileID {
         long_message: String,
         message: String,
     ) {
-        self.diagnostics.push(Diagnostic::new_error(
+        let diagnostic = Diagnostic::new_error(
             long_message,
             Label::new(self.value, location.start..location.end, message),
-        ));
+        );
+        self.diagnostics.push((location, diagnostic));
     }
-    pub(crate) fn report(&self, reporter: &Reporter) {
-        let writer = StandardStream::stderr(ColorChoice::Auto);
-        let config = codespan_reporting::term::Config::default();
-        for diagnostic in &self.diagnostics {
-            emit(&mut writer.lock(), &config, &reporter.files, &diagnostic).unwrap();
+    pub(crate) fn add_warning(&mut self, location: Location, message: String) {
+        let diagnostic = Diagnostic::new_warning(
+            message.clone(),
+            Label::new(self.value, location.start..location.end, message),
+        );
+        self.diagnostics.push((location, diagnostic));
+    }
+    pub(crate) fn report(&self, reporter: &mut Reporter) {
+        for (location, diagnostic) in &self.diagnostics {
+            reporter.add(location.clone(), diagnostic.clone());
         }
     }
 }
+
+fn is_error(diagnostic: &Diagnostic) -> bool {
+    match diagnostic.severity {
+        Severity::Bug | Severity::Error => true,
+        _ => false,
+    }
+}
+
+#[cfg(test)]
+mod tests;
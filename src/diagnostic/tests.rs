@@ -0,0 +1,164 @@
+use super::*;
+
+#[test]
+fn clear_reporter() {
+    let mut reporter = Reporter::new();
+    let mut file = reporter.for_file("test.elz", "x: int = \"str\";");
+    file.add_diagnostic(
+        Location::new("test.elz", 1, 9, 9, 14),
+        "type mismatched".to_string(),
+        "type mismatched".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(reporter.has_errors(), true);
+
+    reporter.clear();
+    assert_eq!(reporter.has_errors(), false);
+
+    // reuse the cleared reporter for another compilation
+    let file = reporter.for_file("test.elz", "x: int = 1;");
+    file.report(&mut reporter);
+    assert_eq!(reporter.has_errors(), false);
+}
+
+#[test]
+fn messages_with_location() {
+    let mut reporter = Reporter::new();
+    let mut file = reporter.for_file("test.elz", "x: int = \"str\";");
+    file.add_diagnostic(
+        Location::new("test.elz", 1, 9, 9, 14),
+        "type mismatched, expected: `int` but got: `string`".to_string(),
+        "type mismatched".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(
+        reporter.messages(),
+        vec!["test.elz:1:9: type mismatched, expected: `int` but got: `string`"]
+    );
+}
+
+#[test]
+fn warning_is_not_an_error() {
+    let mut reporter = Reporter::new();
+    let mut file = reporter.for_file("test.elz", "x: int = 1;");
+    file.add_warning(
+        Location::new("test.elz", 1, 0, 0, 1),
+        "name `x` should be `X`".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(reporter.has_errors(), false);
+    assert_eq!(reporter.messages().len(), 1);
+
+    let mut file = reporter.for_file("test.elz", "x: int = 1;");
+    file.add_diagnostic(
+        Location::new("test.elz", 1, 3, 3, 6),
+        "no type named: `int`".to_string(),
+        "no type named".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(reporter.has_errors(), true);
+}
+
+#[test]
+fn messages_are_sorted_by_location() {
+    let mut reporter = Reporter::new();
+    let code = "x: int = \"str\";\ny: int = true;";
+    let mut file = reporter.for_file("b.elz", code);
+    file.add_warning(Location::none(), "no position".to_string());
+    file.add_warning(Location::new("b.elz", 2, 9, 25, 29), "second".to_string());
+    file.add_warning(Location::new("b.elz", 1, 9, 9, 14), "first".to_string());
+    file.report(&mut reporter);
+    let mut file = reporter.for_file("a.elz", "z: int = 1;");
+    file.add_warning(Location::new("a.elz", 1, 0, 0, 1), "other file".to_string());
+    file.report(&mut reporter);
+    assert_eq!(
+        reporter.messages(),
+        vec![
+            "a.elz:1:0: other file",
+            "b.elz:1:9: first",
+            "b.elz:2:9: second",
+            ":0:0: no position",
+        ]
+    );
+}
+
+#[test]
+fn messages_with_source_line() {
+    let mut reporter = Reporter::new();
+    let code = "x: int = 1;\n\ty: int = 3.2;";
+    let mut file = reporter.for_file("test.elz", code);
+    file.add_diagnostic(
+        Location::new("test.elz", 2, 10, 22, 25),
+        "type mismatched".to_string(),
+        "type mismatched".to_string(),
+    );
+    file.add_warning(Location::none(), "no position".to_string());
+    // a line past the end of source has no source line either
+    file.add_warning(
+        Location::new("test.elz", 9, 0, 26, 26),
+        "past the end".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(
+        reporter.messages_with_source(),
+        vec![
+            "test.elz:2:10: type mismatched\n    y: int = 3.2;\n             ^",
+            "test.elz:9:0: past the end",
+            ":0:0: no position",
+        ]
+    );
+}
+
+#[test]
+fn stop_after_max_errors() {
+    let mut reporter = Reporter::new();
+    reporter.set_max_errors(2);
+    let mut file = reporter.for_file("test.elz", "a b c d");
+    for (i, name) in vec!["a", "b", "c", "d"].iter().enumerate() {
+        let column = i as u32 * 2;
+        file.add_diagnostic(
+            Location::new("test.elz", 1, column, column, column + 1),
+            format!("no variable named: `{}`", name),
+            "no variable named".to_string(),
+        );
+    }
+    file.add_warning(
+        Location::new("test.elz", 1, 6, 6, 7),
+        "warning is not limited".to_string(),
+    );
+    file.report(&mut reporter);
+    assert_eq!(
+        reporter.messages(),
+        vec![
+            "test.elz:1:0: no variable named: `a`",
+            "test.elz:1:2: no variable named: `b`",
+            "test.elz:1:6: warning is not limited",
+            ":0:0: too many errors, stopping after 2 errors",
+        ]
+    );
+}
+
+#[test]
+fn zero_max_errors_is_no_limit() {
+    let mut reporter = Reporter::new();
+    reporter.set_max_errors(2);
+    reporter.set_max_errors(0);
+    let mut file = reporter.for_file("test.elz", "a b c");
+    for (i, name) in vec!["a", "b", "c"].iter().enumerate() {
+        let column = i as u32 * 2;
+        file.add_diagnostic(
+            Location::new("test.elz", 1, column, column, column + 1),
+            format!("no variable named: `{}`", name),
+            "no variable named".to_string(),
+        );
+    }
+    file.report(&mut reporter);
+    assert_eq!(
+        reporter.messages(),
+        vec![
+            "test.elz:1:0: no variable named: `a`",
+            "test.elz:1:2: no variable named: `b`",
+            "test.elz:1:4: no variable named: `c`",
+        ]
+    );
+}
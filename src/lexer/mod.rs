@@ -9,8 +9,12 @@ pub enum TkType {
     Identifier,
     #[strum(serialize = "<integer>")]
     Integer,
+    #[strum(serialize = "<float>")]
+    Float,
     #[strum(serialize = "<string>")]
     String,
+    #[strum(serialize = "<char>")]
+    Char,
     // keyword
     #[strum(serialize = "module")]
     Module,
@@ -26,6 +30,20 @@ pub enum TkType {
     If,
     #[strum(serialize = "else")]
     Else,
+    #[strum(serialize = "loop")]
+    Loop,
+    #[strum(serialize = "while")]
+    While,
+    #[strum(serialize = "break")]
+    Break,
+    #[strum(serialize = "continue")]
+    Continue,
+    #[strum(serialize = "as")]
+    As,
+    #[strum(serialize = "mut")]
+    Mut,
+    #[strum(serialize = "match")]
+    Match,
     #[strum(serialize = "true")]
     True,
     #[strum(serialize = "false")]
@@ -39,10 +57,34 @@ pub enum TkType {
     Multiple,
     #[strum(serialize = "/")]
     Divide,
+    #[strum(serialize = "%")]
+    Percent,
+    #[strum(serialize = "^")]
+    Caret,
+    #[strum(serialize = "&&")]
+    AndAnd,
+    #[strum(serialize = "||")]
+    OrOr,
     #[strum(serialize = ",")]
     Comma,
     #[strum(serialize = "=")]
     Equal,
+    #[strum(serialize = "==")]
+    EqualEqual,
+    #[strum(serialize = "=>")]
+    FatArrow,
+    #[strum(serialize = "!=")]
+    NotEqual,
+    #[strum(serialize = "!")]
+    Not,
+    #[strum(serialize = "<")]
+    LessThan,
+    #[strum(serialize = "<=")]
+    LessThanOrEqual,
+    #[strum(serialize = ">")]
+    GreaterThan,
+    #[strum(serialize = ">=")]
+    GreaterThanOrEqual,
     #[strum(serialize = "(")]
     OpenParen,
     #[strum(serialize = ")")]
@@ -70,6 +112,9 @@ pub enum TkType {
     // ignored
     #[strum(serialize = "<comment>")]
     Comment,
+    // invalid input, e.g. unterminated block comment, illegal char
+    #[strum(serialize = "<error>")]
+    Error,
 }
 
 #[derive(Clone, Debug)]
@@ -85,6 +130,15 @@ impl Location {
     pub fn none() -> Location {
         Location::from(0, 0)
     }
+    pub fn file_name(&self) -> &str {
+        self.file_name.as_str()
+    }
+    pub fn line(&self) -> u32 {
+        self.line
+    }
+    pub fn column(&self) -> u32 {
+        self.column
+    }
     pub fn from(line: u32, column: u32) -> Location {
         Location::new("", line, column, 0, 0)
     }
@@ -153,6 +207,11 @@ struct Lexer {
     file_name: String,
     code: Vec<char>,
     tokens: Vec<Token>,
+    // comments are not part of token stream, but kept for tools like formatter
+    comments: Vec<Token>,
+    // when divert_errors is set, error tokens are kept here instead of in the token stream
+    divert_errors: bool,
+    errors: Vec<Token>,
     state_fn: State,
     start: usize,
     offset: usize,
@@ -167,6 +226,9 @@ impl Lexer {
             file_name: file_name.into(),
             code: code.into().chars().collect(),
             tokens: vec![],
+            comments: vec![],
+            divert_errors: false,
+            errors: vec![],
             state_fn: State::Fn(whitespace),
             start: 0,
             offset: 0,
@@ -214,14 +276,32 @@ impl Lexer {
             "trait" => self.new_token(TkType::Trait, s),
             "if" => self.new_token(TkType::If, s),
             "else" => self.new_token(TkType::Else, s),
+            "loop" => self.new_token(TkType::Loop, s),
+            "while" => self.new_token(TkType::While, s),
+            "break" => self.new_token(TkType::Break, s),
+            "continue" => self.new_token(TkType::Continue, s),
+            "as" => self.new_token(TkType::As, s),
+            "mut" => self.new_token(TkType::Mut, s),
+            "match" => self.new_token(TkType::Match, s),
             _ => self.new_token(token_type.clone(), s),
         };
         match token_type {
-            TkType::Comment => {}
+            TkType::Comment => self.comments.push(tok),
+            TkType::Error if self.divert_errors => self.errors.push(tok),
             _ => self.tokens.push(tok),
         }
         self.ignore();
     }
+    /// emit_error emits an error token which value explains the error instead of source text
+    fn emit_error(&mut self, message: String) {
+        let tok = self.new_token(TkType::Error, message);
+        if self.divert_errors {
+            self.errors.push(tok);
+        } else {
+            self.tokens.push(tok);
+        }
+        self.ignore();
+    }
 }
 
 fn whitespace(lexer: &mut Lexer) -> State {
@@ -245,7 +325,15 @@ fn whitespace(lexer: &mut Lexer) -> State {
         Some(_c @ '0'..='9') => State::Fn(number),
         Some('=') => {
             lexer.next();
-            lexer.emit(TkType::Equal);
+            if lexer.peek() == Some('=') {
+                lexer.next();
+                lexer.emit(TkType::EqualEqual);
+            } else if lexer.peek() == Some('>') {
+                lexer.next();
+                lexer.emit(TkType::FatArrow);
+            } else {
+                lexer.emit(TkType::Equal);
+            }
             State::Fn(whitespace)
         }
         Some(',') => {
@@ -253,6 +341,16 @@ fn whitespace(lexer: &mut Lexer) -> State {
             lexer.emit(TkType::Comma);
             State::Fn(whitespace)
         }
+        Some('!') => {
+            lexer.next();
+            if lexer.peek() == Some('=') {
+                lexer.next();
+                lexer.emit(TkType::NotEqual);
+            } else {
+                lexer.emit(TkType::Not);
+            }
+            State::Fn(whitespace)
+        }
         Some('+') => {
             lexer.next();
             lexer.emit(TkType::Plus);
@@ -281,11 +379,40 @@ fn whitespace(lexer: &mut Lexer) -> State {
                     }
                 }
                 lexer.emit(TkType::Comment);
+            } else if lexer.peek() == Some('*') {
+                // found `/*`
+                lexer.next();
+                block_comment(lexer);
             } else {
                 lexer.emit(TkType::Divide);
             }
             State::Fn(whitespace)
         }
+        Some('%') => {
+            lexer.next();
+            lexer.emit(TkType::Percent);
+            State::Fn(whitespace)
+        }
+        Some('^') => {
+            lexer.next();
+            lexer.emit(TkType::Caret);
+            State::Fn(whitespace)
+        }
+        Some(c @ '&') | Some(c @ '|') => {
+            lexer.next();
+            if lexer.peek() == Some(c) {
+                lexer.next();
+                lexer.emit(if c == '&' {
+                    TkType::AndAnd
+                } else {
+                    TkType::OrOr
+                });
+            } else {
+                // no bitwise operator
+                lexer.emit(TkType::Error);
+            }
+            State::Fn(whitespace)
+        }
         Some('(') => {
             lexer.next();
             lexer.emit(TkType::OpenParen);
@@ -321,8 +448,21 @@ fn whitespace(lexer: &mut Lexer) -> State {
             if lexer.peek() == Some(':') {
                 lexer.next();
                 lexer.emit(TkType::IsSubTypeOf);
+            } else if lexer.peek() == Some('=') {
+                lexer.next();
+                lexer.emit(TkType::LessThanOrEqual);
             } else {
-                unimplemented!("less than operator");
+                lexer.emit(TkType::LessThan);
+            }
+            State::Fn(whitespace)
+        }
+        Some('>') => {
+            lexer.next();
+            if lexer.peek() == Some('=') {
+                lexer.next();
+                lexer.emit(TkType::GreaterThanOrEqual);
+            } else {
+                lexer.emit(TkType::GreaterThan);
             }
             State::Fn(whitespace)
         }
@@ -352,11 +492,14 @@ fn whitespace(lexer: &mut Lexer) -> State {
             State::Fn(whitespace)
         }
         Some('"') => State::Fn(string),
+        Some('\'') => State::Fn(character),
         Some(c) => {
             if in_identifier_set(c) {
                 State::Fn(ident)
             } else {
-                unimplemented!("char: `{}`", c);
+                lexer.next();
+                lexer.emit(TkType::Error);
+                State::Fn(whitespace)
             }
         }
         None => State::EOF,
@@ -368,46 +511,379 @@ fn in_identifier_set(c: char) -> bool {
 }
 
 fn ident(lexer: &mut Lexer) -> State {
+    ident_chars(lexer);
+    lexer.emit(TkType::Identifier);
+    State::Fn(whitespace)
+}
+
+fn ident_chars(lexer: &mut Lexer) {
     while let Some(c) = lexer.next() {
         if !in_identifier_set(c) {
             break;
         }
     }
-    lexer.emit(TkType::Identifier);
-    State::Fn(whitespace)
 }
 
+/// string keeps escape sequences as is, parser would decode them, a string with unknown escape
+/// sequence or without closing `"` is an error. `\{` and `\}` are literal braces, since a bare `{`
+/// starts a template expression
 fn string(lexer: &mut Lexer) -> State {
-    while let Some(c) = lexer.next() {
-        if c == '\\' {
-            lexer.next();
+    // the token is located at the opening `"`, `lexer.line` moves only after it's emitted
+    let start_line = lexer.line;
+    let mut newlines = 0;
+    let mut line_start = 0;
+    let mut bad_escape = None;
+    loop {
+        match lexer.next() {
+            Some('"') => break,
+            Some('\\') => match lexer.next() {
+                Some('n') | Some('t') | Some('r') | Some('0') | Some('"') | Some('\\')
+                | Some('{') | Some('}') => {}
+                Some('x') | Some('u') => match unicode_escape(&lexer.code[lexer.offset..]) {
+                    // stay at the last char of the escape, the loop moves past it
+                    Ok((_, len)) => lexer.offset += len - 1,
+                    Err(err) => {
+                        if bad_escape.is_none() {
+                            bad_escape = Some(err);
+                        }
+                    }
+                },
+                Some(c) => {
+                    if bad_escape.is_none() {
+                        bad_escape = Some(format!("unknown escape `\\{}`", c));
+                    }
+                    if c == '\n' {
+                        newlines += 1;
+                        line_start = lexer.offset + 1;
+                    }
+                }
+                None => break,
+            },
+            Some('\n') => {
+                newlines += 1;
+                line_start = lexer.offset + 1;
+            }
+            Some(_) => {}
+            None => break,
         }
-        if c == '"' {
+    }
+    let terminated = lexer.peek() == Some('"');
+    if terminated {
+        lexer.next();
+    }
+    match (terminated, bad_escape) {
+        (false, _) => lexer.emit_error(format!(
+            "unterminated string literal starting at line {}",
+            start_line
+        )),
+        (true, None) => lexer.emit(TkType::String),
+        (true, Some(err)) => lexer.emit_error(err),
+    }
+    if newlines > 0 {
+        lexer.line += newlines;
+        lexer.pos = (lexer.offset - line_start) as u32;
+    }
+    State::Fn(whitespace)
+}
+
+/// character lexes one char or escape sequence in `'`, e.g. `'a'`, `'\n'`, `'\x41'`, while `''` and
+/// `'ab'` are errors, parser would decode it
+fn character(lexer: &mut Lexer) -> State {
+    let mut content = vec![];
+    // skip `'`
+    lexer.next();
+    while let Some(c) = lexer.peek() {
+        if c == '\'' || c == '\n' {
             break;
         }
+        content.push(c);
+        lexer.next();
+        if c == '\\' {
+            if let Some(escaped) = lexer.peek() {
+                content.push(escaped);
+                lexer.next();
+            }
+        }
+    }
+    let closed = lexer.peek() == Some('\'');
+    if closed {
+        lexer.next();
+    }
+    let valid = match content.as_slice() {
+        ['\\', 'n'] | ['\\', 't'] | ['\\', '\\'] | ['\\', '\''] => Ok(true),
+        ['\\', escape @ ..] if escape.first() == Some(&'x') || escape.first() == Some(&'u') => {
+            // the escape must take the whole literal, e.g. `'\x410'` is two chars
+            unicode_escape(escape).map(|(_, len)| len == escape.len())
+        }
+        [c] => Ok(*c != '\\'),
+        _ => Ok(false),
+    };
+    match valid {
+        Ok(true) if closed => lexer.emit(TkType::Char),
+        Err(err) if closed => lexer.emit_error(err),
+        _ => lexer.emit(TkType::Error),
     }
-    lexer.next();
-    lexer.emit(TkType::String);
     State::Fn(whitespace)
 }
 
+/// unicode_escape decodes the escape `x41` or `u{1F600}` after a `\`, it returns the char and how
+/// many chars the escape takes. `\x` takes exactly two hex digits up to `7F`, so it's always ASCII,
+/// and `\u{}` takes one to six hex digits of a unicode scalar value
+pub(crate) fn unicode_escape(s: &[char]) -> Result<(char, usize), String> {
+    let hex_digits = |from: usize| -> String {
+        s[from..]
+            .iter()
+            .take_while(|c| c.is_ascii_hexdigit())
+            .collect()
+    };
+    let (digits, len) = match s.first() {
+        Some('x') => {
+            let digits: String = hex_digits(1).chars().take(2).collect();
+            if digits.len() < 2 {
+                return Err("incomplete escape `\\x`, it takes two hex digits".to_string());
+            }
+            (digits, 3)
+        }
+        Some('u') => {
+            let digits = if s.get(1) == Some(&'{') {
+                hex_digits(2)
+            } else {
+                String::new()
+            };
+            if digits.is_empty() || s.get(digits.len() + 2) != Some(&'}') {
+                return Err("incomplete escape `\\u`, it takes hex digits in `{}`".to_string());
+            }
+            if digits.len() > 6 {
+                return Err(format!(
+                    "escape `\\u{{{}}}` is too wide, it takes at most six hex digits",
+                    digits
+                ));
+            }
+            let len = digits.len() + 3;
+            (digits, len)
+        }
+        _ => unreachable!("unicode_escape only decodes `\\x` and `\\u`"),
+    };
+    let value = u32::from_str_radix(&digits, 16).unwrap();
+    let c = match (s[0], std::char::from_u32(value)) {
+        ('x', _) if value > 0x7F => {
+            return Err(format!(
+                "escape `\\x{}` is out of range, it must be at most `\\x7F`",
+                digits
+            ))
+        }
+        (_, Some(c)) => c,
+        (_, None) => {
+            return Err(format!(
+                "escape `\\u{{{}}}` is not a unicode scalar value",
+                digits
+            ))
+        }
+    };
+    Ok((c, len))
+}
+
+/// block_comment lexes the rest of a block comment after `/*`, block comments can be nested, e.g.
+/// `/* a /* b */ c */` is one comment
+fn block_comment(lexer: &mut Lexer) {
+    let mut depth = 1;
+    let mut newlines = 0;
+    let mut line_start = 0;
+    while depth > 0 {
+        let c = match lexer.peek() {
+            Some(c) => c,
+            None => break,
+        };
+        let next_c = lexer.code.get(lexer.offset + 1).cloned();
+        match (c, next_c) {
+            ('/', Some('*')) => {
+                depth += 1;
+                lexer.next();
+            }
+            ('*', Some('/')) => {
+                depth -= 1;
+                lexer.next();
+            }
+            ('\n', _) => {
+                newlines += 1;
+                line_start = lexer.offset + 1;
+            }
+            _ => {}
+        }
+        lexer.next();
+    }
+    if depth > 0 {
+        lexer.emit(TkType::Error);
+    } else {
+        lexer.emit(TkType::Comment);
+    }
+    if newlines > 0 {
+        lexer.line += newlines;
+        lexer.pos = (lexer.offset - line_start) as u32;
+    }
+}
+
+/// number lexes integer and float, `_` can separate digits, e.g. `1_000`, `0xFF_FF`, `1_000.5e3`,
+/// a type suffix can follow the number, e.g. `300'i8`, `1.5'f32`
 fn number(lexer: &mut Lexer) -> State {
-    while let Some(c) = lexer.next() {
-        if !c.is_digit(10) {
-            break;
+    let radix = match (lexer.peek(), lexer.code.get(lexer.offset + 1)) {
+        (Some('0'), Some('x')) | (Some('0'), Some('X')) => Some(16),
+        (Some('0'), Some('o')) | (Some('0'), Some('O')) => Some(8),
+        (Some('0'), Some('b')) | (Some('0'), Some('B')) => Some(2),
+        _ => None,
+    };
+    let mut is_float = false;
+    let result = match radix {
+        Some(radix) => radix_number(lexer, radix),
+        None => decimal_number(lexer, &mut is_float),
+    };
+    if let Err(message) = result {
+        // skip the rest of the literal, e.g. `2` of `0b12`
+        while lexer.peek().map_or(false, in_identifier_set) {
+            lexer.next();
         }
+        lexer.emit_error(message);
+        return State::Fn(whitespace);
+    }
+    // `'` followed by a letter is a suffix rather than a char literal, e.g. `'i8` of `300'i8`
+    let is_suffix = match lexer.code.get(lexer.offset + 1) {
+        Some(c) => lexer.peek() == Some('\'') && c.is_alphabetic(),
+        None => false,
+    };
+    if is_suffix {
+        lexer.next();
+        ident_chars(lexer);
+    }
+    if is_float {
+        lexer.emit(TkType::Float);
+    } else {
+        lexer.emit(TkType::Integer);
     }
-    lexer.emit(TkType::Integer);
     State::Fn(whitespace)
 }
 
+/// radix_number lexes the prefix and digits of a prefixed integer, e.g. `0xFF`, a digit out of the
+/// radix is an error, e.g. `2` in `0b12`
+fn radix_number(lexer: &mut Lexer, radix: u32) -> Result<(), String> {
+    let prefix: String = lexer.code[lexer.offset..lexer.offset + 2].iter().collect();
+    lexer.offset += 2;
+    // `0x` or `0x_` without any digit
+    if digits(lexer, radix)? == 0 {
+        return Err(format!("no digits after `{}`", prefix));
+    }
+    match lexer.peek() {
+        Some(c) if c.is_alphanumeric() => {
+            let name = match radix {
+                16 => "hexadecimal",
+                8 => "octal",
+                _ => "binary",
+            };
+            Err(format!("invalid digit `{}` in {} literal", c, name))
+        }
+        _ => Ok(()),
+    }
+}
+
+/// decimal_number lexes an integer or a float, `is_float` is set when there is a fraction or an
+/// exponent
+fn decimal_number(lexer: &mut Lexer, is_float: &mut bool) -> Result<(), String> {
+    digits(lexer, 10)?;
+    // `1.5` is a float, but `1.` followed by non-digit is not
+    let is_fraction = match lexer.code.get(lexer.offset + 1) {
+        Some(c) => lexer.peek() == Some('.') && c.is_digit(10),
+        None => false,
+    };
+    if is_fraction {
+        lexer.next();
+        digits(lexer, 10)?;
+        *is_float = true;
+    }
+    // exponent must have digits, e.g. `1e3`, `1.5E-3`, but not `1e`
+    if lexer.peek() == Some('e') || lexer.peek() == Some('E') {
+        let sign_len = match lexer.code.get(lexer.offset + 1) {
+            Some('+') | Some('-') => 1,
+            _ => 0,
+        };
+        let has_digit = match lexer.code.get(lexer.offset + 1 + sign_len) {
+            Some(c) => c.is_digit(10),
+            None => false,
+        };
+        if has_digit {
+            lexer.offset += 1 + sign_len;
+            digits(lexer, 10)?;
+            *is_float = true;
+        }
+    }
+    Ok(())
+}
+
+/// digits lexes digits of radix from the current char and returns how many digits there are, a
+/// `_` must be followed by a digit, so `1__0` and `1_` are errors
+fn digits(lexer: &mut Lexer, radix: u32) -> Result<usize, String> {
+    let mut count = 0;
+    while let Some(c) = lexer.peek() {
+        if c == '_' {
+            match lexer.code.get(lexer.offset + 1) {
+                Some('_') => return Err("consecutive `_` in number literal".to_string()),
+                // a wrong digit after `_` is reported by the caller, e.g. `0b1_2`
+                Some(c) if c.is_digit(radix) || (radix != 10 && c.is_alphanumeric()) => {}
+                _ => return Err("number literal cannot end with `_`".to_string()),
+            }
+        } else if c.is_digit(radix) {
+            count += 1;
+        } else {
+            break;
+        }
+        lexer.next();
+    }
+    Ok(count)
+}
+
+/// position_at maps a byte offset in source to (line, column) like Location, line starts from 1
+/// and column counts chars from 0, e.g. `position_at("a\nλb", 4)` is `(2, 1)`
+pub fn position_at(source: &str, offset: usize) -> (u32, u32) {
+    let mut line = 1;
+    let mut column = 0;
+    for (index, c) in source.char_indices() {
+        if index >= offset {
+            break;
+        }
+        if c == '\n' {
+            line += 1;
+            column = 0;
+        } else {
+            column += 1;
+        }
+    }
+    (line, column)
+}
+
 pub fn lex<T: Into<String>>(file_name: T, source: T) -> Vec<Token> {
+    let (tokens, _) = lex_with_comments(file_name, source);
+    tokens
+}
+
+/// lex_with_comments produces the same tokens as lex, and all comments with their location in
+/// source order, so tools like formatter can put comments back without parsing them
+pub fn lex_with_comments<T: Into<String>>(file_name: T, source: T) -> (Vec<Token>, Vec<Token>) {
+    let mut lexer = Lexer::new(file_name, source);
+    while let State::Fn(f) = lexer.state_fn {
+        lexer.state_fn = f(&mut lexer);
+    }
+    lexer.emit(TkType::EOF);
+    (lexer.tokens, lexer.comments)
+}
+
+/// lex_with_errors produces tokens without any error token, and errors separately, so parser can
+/// keep going on a clean token stream while errors are still reported
+pub fn lex_with_errors<T: Into<String>>(file_name: T, source: T) -> (Vec<Token>, Vec<Token>) {
     let mut lexer = Lexer::new(file_name, source);
+    lexer.divert_errors = true;
     while let State::Fn(f) = lexer.state_fn {
         lexer.state_fn = f(&mut lexer);
     }
     lexer.emit(TkType::EOF);
-    lexer.tokens
+    (lexer.tokens, lexer.errors)
 }
 
 #[cfg(test)]
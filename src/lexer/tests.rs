@@ -3,7 +3,7 @@ use super::*;
 
 #[test]
 fn test_symbols() {
-    let code = "+ - * / , = ( ) [ ] { } : :: ; . <: @";
+    let code = "+ - * / % , = ( ) [ ] { } : :: ; . <: @ =>";
 
     let tokens = lex("", code);
     let tk_types: Vec<_> = tokens.iter().map(|tok| tok.tk_type()).collect();
@@ -15,6 +15,7 @@ fn test_symbols() {
             &Minus,
             &Multiple,
             &Divide,
+            &Percent,
             &Comma,
             &Equal,
             &OpenParen,
@@ -29,6 +30,7 @@ fn test_symbols() {
             &Dot,
             &IsSubTypeOf,
             &AtSign,
+            &FatArrow,
             &EOF,
         ]
     )
@@ -36,14 +38,18 @@ fn test_symbols() {
 
 #[test]
 fn test_keywords() {
-    let code = "module import return class trait true false if else";
+    let code =
+        "module import return class trait true false if else loop while break continue as mut match";
 
     let tokens = lex("", code);
     let tk_types: Vec<_> = tokens.iter().map(|tok| tok.tk_type()).collect();
     use TkType::*;
     assert_eq!(
         tk_types,
-        vec![&Module, &Import, &Return, &Class, &Trait, &True, &False, &If, &Else, &EOF]
+        vec![
+            &Module, &Import, &Return, &Class, &Trait, &True, &False, &If, &Else, &Loop, &While,
+            &Break, &Continue, &As, &Mut, &Match, &EOF
+        ]
     )
 }
 
@@ -77,6 +83,191 @@ fn get_number_tokens() {
     );
 }
 
+#[test]
+fn get_float_tokens() {
+    let ts = lex("", "1.5 2.");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), Float, "1.5".to_string()),
+            Token(Location::from(1, 4), Integer, "2".to_string()),
+            Token(Location::from(1, 5), Dot, ".".to_string()),
+            Token(Location::from(1, 6), EOF, "".to_string()),
+        ]
+    );
+}
+
+#[test]
+fn plus_is_always_an_operator() {
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(tk_types("+123"), vec![Plus, Integer, EOF]);
+    assert_eq!(tk_types("a+1"), vec![Identifier, Plus, Integer, EOF]);
+}
+
+#[test]
+fn get_radix_number_tokens() {
+    let ts = lex("", "0xFF 0b1010 0x");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), Integer, "0xFF".to_string()),
+            Token(Location::from(1, 5), Integer, "0b1010".to_string()),
+            Token(
+                Location::from(1, 12),
+                Error,
+                "no digits after `0x`".to_string()
+            ),
+            Token(Location::from(1, 14), EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn get_number_tokens_with_separator_and_exponent() {
+    let ts = lex("", "1_000 0o7_7 1_000.5e3 1E-2 1e");
+    let ts: Vec<_> = ts
+        .iter()
+        .map(|tok| (tok.tk_type().clone(), tok.value()))
+        .collect();
+    assert_eq!(
+        ts,
+        vec![
+            (Integer, "1_000".to_string()),
+            (Integer, "0o7_7".to_string()),
+            (Float, "1_000.5e3".to_string()),
+            (Float, "1E-2".to_string()),
+            (Integer, "1".to_string()),
+            (Identifier, "e".to_string()),
+            (EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn bad_number_literal_is_an_error() {
+    let ts: Vec<_> = lex("", "1__0 1_ 0b12 0xFG_1 1_.5 2")
+        .iter()
+        .map(|tok| (tok.tk_type().clone(), tok.value()))
+        .collect();
+    assert_eq!(
+        ts,
+        vec![
+            (Error, "consecutive `_` in number literal".to_string()),
+            (Error, "number literal cannot end with `_`".to_string()),
+            (Error, "invalid digit `2` in binary literal".to_string()),
+            (
+                Error,
+                "invalid digit `G` in hexadecimal literal".to_string()
+            ),
+            (Error, "number literal cannot end with `_`".to_string()),
+            (Dot, ".".to_string()),
+            (Integer, "5".to_string()),
+            (Integer, "2".to_string()),
+            (EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn get_number_tokens_with_suffix() {
+    let ts: Vec<_> = lex("", "300'i8 0xFF_FF'i32 1_000.5e3'f64 'a'")
+        .iter()
+        .map(|tok| (tok.tk_type().clone(), tok.value()))
+        .collect();
+    assert_eq!(
+        ts,
+        vec![
+            (Integer, "300'i8".to_string()),
+            (Integer, "0xFF_FF'i32".to_string()),
+            (Float, "1_000.5e3'f64".to_string()),
+            (Char, "'a'".to_string()),
+            (EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn not_equal_token() {
+    let ts = lex("", "a!=1");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), Identifier, "a".to_string()),
+            Token(Location::from(1, 1), NotEqual, "!=".to_string()),
+            Token(Location::from(1, 3), Integer, "1".to_string()),
+            Token(Location::from(1, 4), EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn comparison_tokens() {
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(
+        tk_types("a >= b != c"),
+        vec![
+            Identifier,
+            GreaterThanOrEqual,
+            Identifier,
+            NotEqual,
+            Identifier,
+            EOF
+        ]
+    );
+    assert_eq!(
+        tk_types("< <= > >= <:"),
+        vec![
+            LessThan,
+            LessThanOrEqual,
+            GreaterThan,
+            GreaterThanOrEqual,
+            IsSubTypeOf,
+            EOF
+        ]
+    );
+    assert_eq!(
+        tk_types("!a != b"),
+        vec![Not, Identifier, NotEqual, Identifier, EOF]
+    );
+}
+
+#[test]
+fn logical_tokens() {
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(
+        tk_types("a && b || c"),
+        vec![Identifier, AndAnd, Identifier, OrOr, Identifier, EOF]
+    );
+    assert_eq!(tk_types("a & b"), vec![Identifier, Error, Identifier, EOF]);
+}
+
+#[test]
+fn equal_equal_token() {
+    let tk_types: Vec<_> = lex("", "a == b = c")
+        .iter()
+        .map(|tok| tok.tk_type().clone())
+        .collect();
+    assert_eq!(
+        tk_types,
+        vec![Identifier, EqualEqual, Identifier, Equal, Identifier, EOF]
+    );
+}
+
 #[test]
 fn get_ident_tokens() {
     let ts = lex("", " abc6");
@@ -101,6 +292,133 @@ fn get_escape_char_in_string() {
     )
 }
 
+#[test]
+fn bad_string_is_an_error() {
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(tk_types("\"a\\n\\t\\0\""), vec![String, EOF]);
+    assert_eq!(tk_types("\"a\\qb\" 1"), vec![Error, Integer, EOF]);
+    assert_eq!(tk_types("\"abc"), vec![Error, EOF]);
+    assert_eq!(tk_types("\"abc\\"), vec![Error, EOF]);
+}
+
+#[test]
+fn get_char_tokens() {
+    let ts = lex("", "'a' '\\n' '\\''");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), Char, "'a'".to_string()),
+            Token(Location::from(1, 4), Char, "'\\n'".to_string()),
+            Token(Location::from(1, 9), Char, "'\\''".to_string()),
+            Token(Location::from(1, 13), EOF, "".to_string()),
+        ]
+    );
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(tk_types("'' 1"), vec![Error, Integer, EOF]);
+    assert_eq!(tk_types("'ab' 1"), vec![Error, Integer, EOF]);
+    assert_eq!(tk_types("'\\q'"), vec![Error, EOF]);
+    assert_eq!(tk_types("'a"), vec![Error, EOF]);
+}
+
+#[test]
+fn unicode_escape_in_char_and_string() {
+    let tk_types = |code| -> Vec<TkType> {
+        lex("", code)
+            .iter()
+            .map(|tok| tok.tk_type().clone())
+            .collect()
+    };
+    assert_eq!(
+        tk_types("'\\x41' '\\u{1F600}' \"a\\x41\\u{1F600}b\""),
+        vec![Char, Char, String, EOF]
+    );
+    // `\x` takes exactly two digits, so `'\x410'` is two chars
+    assert_eq!(tk_types("'\\x410' 1"), vec![Error, Integer, EOF]);
+    let errors = |code| -> Vec<std::string::String> {
+        lex("", code)
+            .iter()
+            .filter(|tok| tok.tk_type() == &Error)
+            .map(|tok| tok.value())
+            .collect()
+    };
+    assert_eq!(
+        errors("'\\x' '\\x80' '\\u41' \"\\u{}\""),
+        vec![
+            "incomplete escape `\\x`, it takes two hex digits",
+            "escape `\\x80` is out of range, it must be at most `\\x7F`",
+            "incomplete escape `\\u`, it takes hex digits in `{}`",
+            "incomplete escape `\\u`, it takes hex digits in `{}`",
+        ]
+    );
+    assert_eq!(
+        errors("'\\u{1000000}' \"\\u{D800}\""),
+        vec![
+            "escape `\\u{1000000}` is too wide, it takes at most six hex digits",
+            "escape `\\u{D800}` is not a unicode scalar value",
+        ]
+    );
+}
+
+#[test]
+fn unknown_escape_in_string() {
+    let ts = lex("", "\"a\\qb\\zc\"");
+    assert_eq!(
+        ts[0],
+        Token(
+            Location::from(1, 0),
+            Error,
+            "unknown escape `\\q`".to_string()
+        )
+    );
+    assert_eq!(ts.len(), 2);
+}
+
+#[test]
+fn unterminated_string() {
+    let ts = lex("", "x\n\"no end");
+    assert_eq!(
+        ts[1],
+        Token(
+            Location::from(2, 0),
+            Error,
+            "unterminated string literal starting at line 2".to_string()
+        )
+    );
+    assert_eq!(ts.len(), 3);
+    let ts = lex("", "x\n\"a\nno end");
+    assert_eq!(
+        ts[1],
+        Token(
+            Location::from(2, 0),
+            Error,
+            "unterminated string literal starting at line 2".to_string()
+        )
+    );
+}
+
+#[test]
+fn token_after_multiline_string() {
+    let ts = lex("", "\"a\nbc\" x");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), String, "\"a\nbc\"".to_string()),
+            Token(Location::from(2, 4), Identifier, "x".to_string()),
+            Token(Location::from(2, 5), EOF, "".to_string()),
+        ]
+    );
+}
+
 #[test]
 fn comment_would_be_discard() {
     let ts = lex("", "//\n1");
@@ -112,3 +430,102 @@ fn comment_would_be_discard() {
         ]
     )
 }
+
+#[test]
+fn block_comment_would_be_discard() {
+    let ts = lex("", "/* a */1");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 7), Integer, "1".to_string()),
+            Token(Location::from(1, 8), EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn nested_block_comment() {
+    let (ts, comments) = lex_with_comments("", "/* a /* b */\n c */ x");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(2, 6), Identifier, "x".to_string()),
+            Token(Location::from(2, 7), EOF, "".to_string()),
+        ]
+    );
+    assert_eq!(
+        comments,
+        vec![Token(
+            Location::from(1, 0),
+            Comment,
+            "/* a /* b */\n c */".to_string()
+        )]
+    );
+}
+
+#[test]
+fn unterminated_block_comment() {
+    let ts = lex("", "/* a /* b */");
+    assert_eq!(
+        ts,
+        vec![
+            Token(Location::from(1, 0), Error, "/* a /* b */".to_string()),
+            Token(Location::from(1, 12), EOF, "".to_string()),
+        ]
+    )
+}
+
+#[test]
+fn collect_comments_beside_tokens() {
+    let code = "// module doc\nx: int = 1; // trailing\n// last";
+    let (tokens, comments) = lex_with_comments("", code);
+    assert_eq!(tokens, lex("", code));
+    assert_eq!(
+        comments,
+        vec![
+            Token(Location::from(1, 0), Comment, "// module doc".to_string()),
+            Token(Location::from(2, 12), Comment, "// trailing".to_string()),
+            Token(Location::from(3, 0), Comment, "// last".to_string()),
+        ]
+    );
+}
+
+#[test]
+fn byte_offset_to_position() {
+    let code = "a: int\nλ: string = \"中文\"";
+    assert_eq!(position_at(code, 0), (1, 0));
+    assert_eq!(position_at(code, 3), (1, 3));
+    // `\n`
+    assert_eq!(position_at(code, 6), (1, 6));
+    // `λ` takes two bytes
+    assert_eq!(position_at(code, 7), (2, 0));
+    assert_eq!(position_at(code, 9), (2, 1));
+    // closing `"`, `中文` are 6 bytes but 2 chars
+    assert_eq!(position_at(code, 27), (2, 15));
+}
+
+#[test]
+fn illegal_char_is_an_error_token() {
+    let tk_types: Vec<_> = lex("", "a $ b")
+        .iter()
+        .map(|tok| tok.tk_type().clone())
+        .collect();
+    assert_eq!(tk_types, vec![Identifier, Error, Identifier, EOF]);
+}
+
+#[test]
+fn divert_errors_from_token_stream() {
+    let (tokens, errors) = lex_with_errors("", "x: int $= 1 ?;");
+    let tk_types: Vec<_> = tokens.iter().map(|tok| tok.tk_type().clone()).collect();
+    assert_eq!(
+        tk_types,
+        vec![Identifier, Colon, Identifier, Equal, Integer, Semicolon, EOF]
+    );
+    assert_eq!(
+        errors,
+        vec![
+            Token(Location::from(1, 7), Error, "$".to_string()),
+            Token(Location::from(1, 12), Error, "?".to_string()),
+        ]
+    );
+}
@@ -1,6 +1,7 @@
 pub mod ast;
 pub mod cmd;
 pub mod codegen;
+mod constant;
 pub mod diagnostic;
 pub mod lexer;
 pub mod parser;
@@ -1,5 +1,6 @@
 use clap::{App, Arg, SubCommand};
 use elz::cmd;
+use elz::semantic::naming::NamingPolicy;
 
 fn main() {
     let matches = App::new("elz")
@@ -12,6 +13,66 @@ fn main() {
                         .help("input file to compile")
                         .required(true)
                         .min_values(1),
+                )
+                .arg(
+                    Arg::with_name("lint-naming")
+                        .long("lint-naming")
+                        .help("warn names not in snake_case(values) or PascalCase(types)"),
+                )
+                .arg(
+                    Arg::with_name("source-map")
+                        .long("source-map")
+                        .takes_value(true)
+                        .value_name("FILE")
+                        .help("write a JSON map from generated globals and functions to source"),
+                )
+                .arg(
+                    Arg::with_name("output")
+                        .short("o")
+                        .long("output")
+                        .takes_value(true)
+                        .value_name("FILE")
+                        .help("write a native object file by `llc` instead of printing LLVM IR"),
+                )
+                .arg(
+                    Arg::with_name("opt-level")
+                        .short("O")
+                        .takes_value(true)
+                        .value_name("LEVEL")
+                        .default_value("0")
+                        .possible_values(&["0", "1", "2", "3"])
+                        .help("optimize LLVM IR by `opt`, 0 disables optimization"),
+                )
+                .arg(
+                    Arg::with_name("debug")
+                        .short("g")
+                        .help("emit DWARF line tables for debugger like gdb or lldb"),
+                ),
+        )
+        .subcommand(
+            SubCommand::with_name(cmd::emit_ir::CMD_NAME)
+                .about("write LLVM IR of input file")
+                .arg(
+                    Arg::with_name("INPUT")
+                        .help("input file, `-` reads from stdin")
+                        .required(true),
+                )
+                .arg(
+                    Arg::with_name("output")
+                        .short("o")
+                        .long("output")
+                        .takes_value(true)
+                        .value_name("FILE")
+                        .help("write LLVM IR to the file instead of printing it"),
+                ),
+        )
+        .subcommand(
+            SubCommand::with_name(cmd::run::CMD_NAME)
+                .about("run `main` of input file by JIT, exit with the value `main` returns")
+                .arg(
+                    Arg::with_name("INPUT")
+                        .help("input file to run")
+                        .required(true),
                 ),
         )
         .subcommand(
@@ -28,9 +89,48 @@ fn main() {
 
     if let Some(compile_args) = matches.subcommand_matches(cmd::compile::CMD_NAME) {
         let files: Vec<_> = compile_args.values_of("INPUT").unwrap().collect();
-        match cmd::compile::compile(files) {
+        let naming_policy = if compile_args.is_present("lint-naming") {
+            Some(NamingPolicy::new())
+        } else {
+            None
+        };
+        let source_map = compile_args.value_of("source-map");
+        let output = compile_args.value_of("output");
+        // clap rejects a level out of `possible_values` with an error to stderr and exit code 1
+        let opt_level = compile_args
+            .value_of("opt-level")
+            .unwrap()
+            .parse()
+            .expect("optimization level is one of 0, 1, 2 and 3");
+        let debug_info = compile_args.is_present("debug");
+        match cmd::compile::compile(
+            files,
+            naming_policy,
+            source_map,
+            output,
+            opt_level,
+            debug_info,
+        ) {
             Ok(..) => (),
-            Err(..) => println!("compile failed"),
+            Err(err) => {
+                eprintln!("compile failed: {}", err);
+                std::process::exit(1);
+            }
+        }
+    } else if let Some(emit_args) = matches.subcommand_matches(cmd::emit_ir::CMD_NAME) {
+        let input = emit_args.value_of("INPUT").unwrap();
+        let output = emit_args.value_of("output");
+        if let Err(err) = cmd::emit_ir::emit_ir(input, output) {
+            eprintln!("emit LLVM IR failed: {}", err);
+            std::process::exit(1);
+        }
+    } else if let Some(run_args) = matches.subcommand_matches(cmd::run::CMD_NAME) {
+        match cmd::run::run(run_args.value_of("INPUT").unwrap()) {
+            Ok(code) => std::process::exit(code),
+            Err(err) => {
+                eprintln!("run failed: {}", err);
+                std::process::exit(1);
+            }
         }
     } else if let Some(compile_args) = matches.subcommand_matches(cmd::fmt::CMD_NAME) {
         let files: Vec<_> = compile_args.values_of("INPUT").unwrap().collect();
@@ -16,6 +16,24 @@ pub enum ParseErrorVariant {
     NotExpectedToken(Vec<TkType>, Token),
     #[error("meet eof when parsing")]
     EOF,
+    #[error("integer literal `{0}` is too large")]
+    IntegerTooLarge(String),
+    #[error("integer literal `{0}` is out of the range of `{1}`")]
+    IntegerOutOfRange(String, String),
+    #[error("invalid suffix `'{1}` of number literal `{0}`")]
+    InvalidSuffix(String, String),
+    #[error(
+        "unsigned integer type `{1}` of number literal `{0}` is not supported, use a signed type"
+    )]
+    UnsignedSuffix(String, String),
+    #[error("list repeat count `{0}` is too large, it must fit in 32 bits")]
+    RepeatCountTooLarge(String),
+    #[error("trailing comma in argument list")]
+    TrailingComma,
+    #[error("cannot infer type of a non-literal expression, please add a type annotation")]
+    CannotInferType,
+    #[error("field `{0}` is initialized more than once")]
+    DuplicatedField(String),
 }
 
 impl ParseError {
@@ -26,6 +44,54 @@ impl ParseError {
             err: NotExpectedToken(expected, actual),
         }
     }
+    pub fn integer_too_large(location: &Location, literal: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::IntegerTooLarge(literal),
+        }
+    }
+    pub fn integer_out_of_range(location: &Location, literal: String, typ: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::IntegerOutOfRange(literal, typ),
+        }
+    }
+    pub fn invalid_suffix(location: &Location, literal: String, suffix: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::InvalidSuffix(literal, suffix),
+        }
+    }
+    pub fn unsigned_suffix(location: &Location, literal: String, suffix: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::UnsignedSuffix(literal, suffix),
+        }
+    }
+    pub fn repeat_count_too_large(location: &Location, literal: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::RepeatCountTooLarge(literal),
+        }
+    }
+    pub fn trailing_comma(location: &Location) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::TrailingComma,
+        }
+    }
+    pub fn cannot_infer_type(location: &Location) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::CannotInferType,
+        }
+    }
+    pub fn duplicated_field(location: &Location, field: String) -> ParseError {
+        ParseError {
+            location: location.clone(),
+            err: ParseErrorVariant::DuplicatedField(field),
+        }
+    }
     pub fn eof(location: &Location) -> ParseError {
         ParseError {
             location: location.clone(),
@@ -41,6 +107,14 @@ impl ParseError {
         match self.err {
             NotExpectedToken(..) => "not expected token",
             EOF => "eof",
+            IntegerTooLarge(..) => "integer too large",
+            IntegerOutOfRange(..) => "integer out of range",
+            InvalidSuffix(..) => "invalid suffix",
+            UnsignedSuffix(..) => "unsigned suffix",
+            RepeatCountTooLarge(..) => "repeat count too large",
+            TrailingComma => "trailing comma",
+            CannotInferType => "cannot infer type",
+            DuplicatedField(..) => "duplicated field",
         }
         .to_string()
     }
@@ -259,7 +259,11 @@ impl Parser {
                 let mut method = self.parse_function(tag)?;
                 method.parameters.insert(
                     0,
-                    Parameter::new("self", ParsedType::TypeName(class_name.clone())),
+                    Parameter::new(
+                        method.location.clone(),
+                        "self",
+                        ParsedType::TypeName(class_name.clone()),
+                    ),
                 );
                 members.push(TraitMember::Method(method));
             }
@@ -268,13 +272,22 @@ impl Parser {
     }
     /// parse_variable:
     ///
-    /// handle `x: int = 1;`
+    /// handle `x: int = 1;`, or `x := 1;` which type is inferred from the literal
     pub fn parse_variable(&mut self, tag: Option<Tag>) -> Result<Variable> {
         let loc = self.peek(0)?.location();
         // x: int = 1;
         let var_name = self.parse_identifier()?;
         // : int = 1;
         self.consume(vec![TkType::Colon])?;
+        if self.peek(0)?.tk_type() == &TkType::Equal {
+            // = 1;
+            self.consume(vec![TkType::Equal])?;
+            let expr = self.parse_expression(None, None)?;
+            return match infer_type(&expr) {
+                Some(typ) => Ok(Variable::new(loc, tag, var_name, typ, expr)),
+                None => Err(ParseError::cannot_infer_type(&expr.location)),
+            };
+        }
         // int = 1;
         let typ = self.parse_type()?;
         // = 1;
@@ -334,10 +347,14 @@ impl Parser {
         let mut params = vec![];
         while self.peek(0)?.tk_type() != &TkType::CloseParen {
             self.predict(vec![TkType::Identifier, TkType::Colon])?;
-            let param_name = self.take()?.value();
+            let param_name = self.take()?;
             self.take()?;
             let typ = self.parse_type()?;
-            params.push(Parameter::new(param_name, typ));
+            params.push(Parameter::new(
+                param_name.location(),
+                param_name.value(),
+                typ,
+            ));
             let tok = self.peek(0)?;
             match tok.tk_type() {
                 TkType::Comma => {
@@ -359,7 +376,7 @@ impl Parser {
     fn parse_body(&mut self) -> Result<Body> {
         let tok = self.peek(0)?;
         match tok.tk_type() {
-            TkType::OpenBrace => Ok(Body::Block(self.parse_block()?)),
+            TkType::OpenBrace => Ok(Body::Block(self.parse_function_block()?)),
             TkType::Equal => {
                 self.consume(vec![TkType::Equal])?;
                 let e = self.parse_expression(None, None)?;
@@ -395,7 +412,17 @@ impl Parser {
     ///
     /// `<identifier>`
     /// | `<identifier> [ <applied-type-parameters> ]`
+    /// | `( <type> (, <type>)+ )`
     pub fn parse_type(&mut self) -> Result<ParsedType> {
+        if self.predict(vec![TkType::OpenParen]).is_ok() {
+            let element_types = self.parse_many(
+                TkType::OpenParen,
+                TkType::CloseParen,
+                TkType::Comma,
+                |parser| parser.parse_type(),
+            )?;
+            return Ok(ParsedType::tuple(element_types));
+        }
         // ensure is <identifier>
         self.predict(vec![TkType::Identifier])?;
         let type_name = self.parse_access_identifier()?;
@@ -421,35 +448,68 @@ impl Parser {
     ///   <statement>*
     /// }
     pub fn parse_block(&mut self) -> Result<Block> {
+        self.parse_block_of(false)
+    }
+    /// parse_function_block is parse_block of a function body, only here the final expression
+    /// without `;` is returned, e.g. `{ x + 1 }`
+    fn parse_function_block(&mut self) -> Result<Block> {
+        self.parse_block_of(true)
+    }
+    fn parse_block_of(&mut self, is_function_body: bool) -> Result<Block> {
         let location = self.peek(0)?.location();
         self.consume(vec![TkType::OpenBrace])?;
         let mut block = Block::new(location);
         while self.peek(0)?.tk_type() != &TkType::CloseBrace {
-            let stmt = self.parse_statement()?;
+            let stmt = self.parse_statement_of(is_function_body)?;
             block.append(stmt);
         }
         self.consume(vec![TkType::CloseBrace])?;
         Ok(block)
     }
     pub fn parse_statement(&mut self) -> Result<Statement> {
+        self.parse_statement_of(false)
+    }
+    fn parse_statement_of(&mut self, is_function_body: bool) -> Result<Statement> {
         let tok = self.peek(0)?;
         match tok.tk_type() {
-            TkType::Identifier => {
-                if self.peek(1)?.tk_type() == &TkType::Colon {
-                    let var = self.parse_variable(None)?;
-                    self.consume(vec![TkType::Semicolon])?;
-                    Ok(Statement::variable(tok.location(), var))
-                } else if vec![TkType::OpenParen, TkType::Dot].contains(self.peek(1)?.tk_type()) {
-                    let unary = self.parse_unary()?;
-                    let expr = self.parse_primary(unary)?;
-                    self.consume(vec![TkType::Semicolon])?;
-                    Ok(Statement::expression(tok.location(), expr))
-                } else {
-                    Err(ParseError::not_expected_token(
-                        vec![TkType::Colon, TkType::OpenParen],
-                        tok,
-                    ))
-                }
+            TkType::Identifier if self.peek(1)?.tk_type() == &TkType::Colon => {
+                let var = self.parse_variable(None)?;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::variable(tok.location(), var))
+            }
+            // `mut x: int = 1;`
+            TkType::Mut => {
+                self.take()?;
+                let mut var = self.parse_variable(None)?;
+                var.mutable = true;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::variable(tok.location(), var))
+            }
+            // `x = 2;`
+            TkType::Identifier if self.peek(1)?.tk_type() == &TkType::Equal => {
+                let name = self.parse_identifier()?;
+                self.consume(vec![TkType::Equal])?;
+                let expr = self.parse_expression(None, None)?;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::assign(tok.location(), name, expr))
+            }
+            // `(x, y): (int, int) = pair();`
+            TkType::OpenParen
+                if self.peek(1)?.tk_type() == &TkType::Identifier
+                    && self.peek(2)?.tk_type() == &TkType::Comma =>
+            {
+                let names = self.parse_many(
+                    TkType::OpenParen,
+                    TkType::CloseParen,
+                    TkType::Comma,
+                    |parser| parser.parse_identifier(),
+                )?;
+                self.consume(vec![TkType::Colon])?;
+                let typ = self.parse_type()?;
+                self.consume(vec![TkType::Equal])?;
+                let expr = self.parse_expression(None, None)?;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::destructure(tok.location(), names, typ, expr))
             }
             // `return 1;`
             TkType::Return => {
@@ -487,7 +547,43 @@ impl Parser {
                     Block::new(tok.location()),
                 ))
             }
-            _ => unimplemented!("{}", tok),
+            // `loop { ... }`
+            TkType::Loop => {
+                self.take()?;
+                Ok(Statement::loop_block(tok.location(), self.parse_block()?))
+            }
+            // `while <condition> { ... }`
+            TkType::While => {
+                self.take()?;
+                let condition = self.parse_expression(None, None)?;
+                Ok(Statement::while_block(
+                    tok.location(),
+                    condition,
+                    self.parse_block()?,
+                ))
+            }
+            // `break;`
+            TkType::Break => {
+                self.take()?;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::break_stmt(tok.location()))
+            }
+            // `continue;`
+            TkType::Continue => {
+                self.take()?;
+                self.consume(vec![TkType::Semicolon])?;
+                Ok(Statement::continue_stmt(tok.location()))
+            }
+            _ => {
+                let expr = self.parse_expression(None, None)?;
+                if is_function_body && self.peek(0)?.tk_type() == &TkType::CloseBrace {
+                    // the final expression without `;` is returned, e.g. `{ x + 1 }`
+                    Ok(Statement::return_stmt(tok.location(), Some(expr)))
+                } else {
+                    self.consume(vec![TkType::Semicolon])?;
+                    Ok(Statement::expression(tok.location(), expr))
+                }
+            }
         }
     }
 }
@@ -502,8 +598,13 @@ impl Parser {
         left_hand_side: Option<Expr>,
         previous_primary: Option<u64>,
     ) -> Result<Expr> {
-        let unary = self.parse_unary()?;
-        let mut lhs = left_hand_side.unwrap_or(self.parse_primary(unary)?);
+        let mut lhs = match left_hand_side {
+            Some(lhs) => lhs,
+            None => {
+                let unary = self.parse_unary()?;
+                self.parse_primary(unary)?
+            }
+        };
         let mut lookahead = self.peek(0)?;
         while precedence(lookahead.clone()) >= previous_primary.unwrap_or(1) {
             let operator = lookahead.clone();
@@ -515,8 +616,7 @@ impl Parser {
                 || (is_right_associative(lookahead.clone())
                     && (precedence(lookahead.clone()) == precedence(operator.clone())))
             {
-                rhs =
-                    self.parse_expression(Some(lhs.clone()), Some(precedence(lookahead.clone())))?;
+                rhs = self.parse_expression(Some(rhs), Some(precedence(lookahead.clone())))?;
                 lookahead = self.peek(0)?;
             }
             lhs = Expr::binary(
@@ -531,10 +631,24 @@ impl Parser {
     /// parse_primary:
     ///
     /// foo()
+    /// | foo.bar
+    /// | foo[i]
+    /// | foo as <type>
     pub fn parse_primary(&mut self, unary: Expr) -> Result<Expr> {
         let tok = self.peek(0)?;
         match tok.tk_type() {
+            TkType::As => {
+                self.consume(vec![TkType::As])?;
+                let typ = self.parse_type()?;
+                self.parse_primary(Expr::cast(tok.location(), unary, typ))
+            }
             TkType::OpenParen => self.parse_function_call(unary),
+            TkType::OpenBracket => {
+                self.consume(vec![TkType::OpenBracket])?;
+                let index = self.parse_expression(None, None)?;
+                self.consume(vec![TkType::CloseBracket])?;
+                self.parse_primary(Expr::index(tok.location(), unary, index))
+            }
             TkType::Dot => {
                 self.consume(vec![TkType::Dot])?;
                 let field_name = self.parse_identifier()?;
@@ -543,35 +657,106 @@ impl Parser {
             _ => Ok(unary),
         }
     }
+    /// is_field_inits is true if the following `{` starts field inits of class construction
+    fn is_field_inits(&self) -> Result<bool> {
+        Ok(match self.peek(1)?.tk_type() {
+            TkType::CloseBrace => true,
+            TkType::Identifier => self.peek(2)?.tk_type() == &TkType::Colon,
+            _ => false,
+        })
+    }
+    /// parse_match_arm:
+    ///
+    /// `1 => a`
+    /// | `_ => b`
+    fn parse_match_arm(&mut self) -> Result<MatchArm> {
+        let tok = self.peek(0)?;
+        let pattern = if tok.tk_type() == &TkType::Identifier && tok.value() == "_" {
+            self.take()?;
+            None
+        } else {
+            Some(self.parse_expression(None, None)?)
+        };
+        self.consume(vec![TkType::FatArrow])?;
+        let expr = self.parse_expression(None, None)?;
+        Ok(MatchArm::new(pattern, expr))
+    }
     /// parse_unary:
     ///
-    /// <integer>
+    /// `+` <unary>
+    /// | `-` <unary>
+    /// | `!` <unary>
+    /// | `(` <expression> `)`
+    /// | `(` <expression> (`,` <expression>)+ `)`
+    /// | <integer>
     /// | <float64>
     /// | <string_literal>
     /// | <access_identifier>
     /// | <bool>
     /// | <list>
+    /// | `match` <expression> `{` <match_arm> (`,` <match_arm>)* `}`
     pub fn parse_unary(&mut self) -> Result<Expr> {
         let tok = self.peek(0)?;
         match tok.tk_type() {
-            // FIXME: lexer should emit int & float token directly
-            TkType::Integer => {
-                let num = self.take()?.value();
-                if num.parse::<i64>().is_ok() {
-                    Ok(Expr::int(tok.location(), num.parse::<i64>().unwrap()))
-                } else if num.parse::<f64>().is_ok() {
-                    Ok(Expr::f64(tok.location(), num.parse::<f64>().unwrap()))
-                } else {
-                    panic!(
-                        "lexing bug causes a number token can't be convert to number: {:?}",
+            TkType::Match => {
+                self.take()?;
+                let expr = self.parse_expression(None, None)?;
+                let arms = self.parse_many(
+                    TkType::OpenBrace,
+                    TkType::CloseBrace,
+                    TkType::Comma,
+                    |parser| parser.parse_match_arm(),
+                )?;
+                Ok(Expr::match_expr(tok.location(), expr, arms))
+            }
+            TkType::Plus | TkType::Minus | TkType::Not => {
+                let op = UnaryOperator::from_token(self.take()?);
+                // a suffixed literal takes its sign, so `-128'i8` is in the range of `i8`
+                let next = self.peek(0)?;
+                if op == UnaryOperator::Minus
+                    && next.tk_type() == &TkType::Integer
+                    && next.value().contains('\'')
+                {
+                    let literal = self.parse_integer(tok.location(), true)?;
+                    return self.parse_primary(literal);
+                }
+                let unary = self.parse_unary()?;
+                let operand = self.parse_primary(unary)?;
+                Ok(Expr::unary(tok.location(), op, operand))
+            }
+            TkType::OpenParen => {
+                self.consume(vec![TkType::OpenParen])?;
+                let expr = self.parse_expression(None, None)?;
+                if self.predict(vec![TkType::Comma]).is_err() {
+                    self.consume(vec![TkType::CloseParen])?;
+                    return Ok(expr);
+                }
+                // `(1, 2)` is a tuple
+                let mut elements = vec![expr];
+                while self.consume(vec![TkType::Comma]).is_ok() {
+                    elements.push(self.parse_expression(None, None)?);
+                }
+                self.consume(vec![TkType::CloseParen])?;
+                Ok(Expr::tuple(tok.location(), elements))
+            }
+            TkType::Integer => self.parse_integer(tok.location(), false),
+            TkType::Float => {
+                let literal = self.take()?.value();
+                let (num, suffix) = split_suffix(&literal);
+                let num = num.replace('_', "");
+                match num.parse::<f64>() {
+                    Ok(f) => float_with_suffix(tok.location(), f, &literal, suffix),
+                    Err(_) => panic!(
+                        "lexing bug causes a float token can't be convert to number: {:?}",
                         num
-                    )
+                    ),
                 }
             }
             TkType::Identifier => {
                 let name = self.parse_access_identifier()?;
                 match self.peek(0)?.tk_type() {
-                    TkType::OpenBrace => {
+                    // `Car {name: "wow"}` or `Car {}`, but not the block of `while a < n { foo(); }`
+                    TkType::OpenBrace if self.is_field_inits()? => {
                         let mut field_inits = HashMap::new();
                         let exprs = self.parse_many(
                             TkType::OpenBrace,
@@ -579,14 +764,20 @@ impl Parser {
                             TkType::Comma,
                             |parser| {
                                 // x: 1
-                                let identifier = parser.take()?.value();
+                                let identifier = parser.take()?;
                                 parser.consume(vec![TkType::Colon])?;
                                 let expr = parser.parse_expression(None, None)?;
                                 Ok((identifier, expr))
                             },
                         )?;
-                        for (name, expr) in exprs {
-                            field_inits.insert(name, expr);
+                        for (field, expr) in exprs {
+                            if field_inits.contains_key(&field.value()) {
+                                return Err(ParseError::duplicated_field(
+                                    &field.location(),
+                                    field.value(),
+                                ));
+                            }
+                            field_inits.insert(field.value(), expr);
                         }
                         Ok(Expr::class_construction(tok.location(), name, field_inits))
                     }
@@ -602,14 +793,34 @@ impl Parser {
                 Ok(Expr::bool(tok.location(), false))
             }
             TkType::String => self.parse_string(),
-            TkType::OpenBracket => {
-                let list = self.parse_list()?;
-                Ok(Expr::list(tok.location(), list))
+            TkType::Char => {
+                // lexer ensures it's `'c'`, `'\c'` or a valid `'\x..'`, `'\u{..}'`
+                let s: Vec<char> = self.take()?.value().chars().collect();
+                let c = match s[1] {
+                    '\\' => match s[2] {
+                        'n' => '\n',
+                        't' => '\t',
+                        'x' | 'u' => lexer::unicode_escape(&s[2..]).unwrap().0,
+                        c => c,
+                    },
+                    c => c,
+                };
+                Ok(Expr::char(tok.location(), c))
             }
+            TkType::OpenBracket => self.parse_list(),
             _ => {
                 use TkType::*;
                 Err(ParseError::not_expected_token(
-                    vec![Integer, Identifier, True, False, String, OpenBracket],
+                    vec![
+                        Plus,
+                        Integer,
+                        Float,
+                        Identifier,
+                        True,
+                        False,
+                        String,
+                        OpenBracket,
+                    ],
                     tok,
                 ))
             }
@@ -634,22 +845,70 @@ impl Parser {
             args.push(Argument::new(expr.location.clone(), identifier, expr));
             if self.predict(vec![TkType::Comma]).is_err() {
                 break;
-            } else {
-                self.consume(vec![TkType::Comma])?;
+            }
+            let comma = self.take()?;
+            // `f(1,)`
+            if self.peek(0)?.tk_type() == &TkType::CloseParen {
+                return Err(ParseError::trailing_comma(&comma.location()));
             }
         }
         self.consume(vec![TkType::CloseParen])?;
 
         Ok(Expr::func_call(func.location.clone(), func, args))
     }
-    pub fn parse_list(&mut self) -> Result<Vec<Expr>> {
-        let list = self.parse_many(
-            TkType::OpenBracket,
-            TkType::CloseBracket,
-            TkType::Comma,
-            |parser| parser.parse_expression(None, None),
-        )?;
-        Ok(list)
+    /// parse_integer parses an integer literal, `negative` is true when it has a `-` sign
+    fn parse_integer(&mut self, location: Location, negative: bool) -> Result<Expr> {
+        let literal = self.take()?.value();
+        let (num, suffix) = split_suffix(&literal);
+        let num = num.replace('_', "");
+        if let Some(suffix) = suffix {
+            return integer_with_suffix(location, &num, &literal, suffix, negative);
+        }
+        if let Some((digits, radix)) = split_radix(&num) {
+            // `0xFF` and `0b1010` take all 64 bits, so `0xFFFFFFFFFFFFFFFF` is `-1`
+            return match u64::from_str_radix(digits, radix) {
+                Ok(n) => Ok(Expr::int(location, n as i64)),
+                Err(_) => Err(ParseError::integer_too_large(&location, num)),
+            };
+        }
+        match num.parse::<i64>() {
+            Ok(n) => Ok(Expr::int(location, n)),
+            // too large to be an `int`, e.g. `9223372036854775808`
+            Err(_) => Ok(Expr::f64(location, num.parse::<f64>().unwrap())),
+        }
+    }
+    /// parse_list:
+    ///
+    /// [1, 2, 3]
+    /// [0; 4], `count` must be an integer literal, the element is evaluated once and copied
+    pub fn parse_list(&mut self) -> Result<Expr> {
+        let location = self.peek(0)?.location();
+        self.consume(vec![TkType::OpenBracket])?;
+        let mut list = vec![];
+        while self.peek(0)?.tk_type() != &TkType::CloseBracket {
+            let expr = self.parse_expression(None, None)?;
+            if list.is_empty() && self.predict(vec![TkType::Semicolon]).is_ok() {
+                self.take()?;
+                self.predict(vec![TkType::Integer])?;
+                let tok = self.take()?;
+                let count = tok.value().replace('_', "");
+                let count = match split_radix(&count) {
+                    Some((digits, radix)) => u32::from_str_radix(digits, radix),
+                    None => count.parse::<u32>(),
+                }
+                .map_err(|_| ParseError::repeat_count_too_large(&tok.location(), tok.value()))?;
+                self.consume(vec![TkType::CloseBracket])?;
+                return Ok(Expr::list_repeat(location, expr, count as usize));
+            }
+            list.push(expr);
+            if self.predict(vec![TkType::Comma]).is_err() {
+                break;
+            } else {
+                self.consume(vec![TkType::Comma])?;
+            }
+        }
+        self.consume(vec![TkType::CloseBracket])?;
+        Ok(Expr::list(location, list))
     }
     pub fn parse_string(&mut self) -> Result<Expr> {
         self.predict(vec![TkType::String])?;
@@ -668,8 +927,17 @@ impl Parser {
                 '\\' => {
                     index += 1;
                     if index < s.len() {
-                        tmp_s.push(s[index]);
-                        index += 1;
+                        let (c, len) = match s[index] {
+                            'n' => ('\n', 1),
+                            't' => ('\t', 1),
+                            'r' => ('\r', 1),
+                            '0' => ('\0', 1),
+                            // lexer ensures the escape is valid
+                            'x' | 'u' => lexer::unicode_escape(&s[index..]).unwrap(),
+                            c => (c, 1),
+                        };
+                        tmp_s.push(c);
+                        index += len;
                     } else {
                         break;
                     }
@@ -707,13 +975,158 @@ impl Parser {
     }
 }
 
-fn is_right_associative(_op: Token) -> bool {
-    false
+/// integer_with_suffix gives an integer literal the type of its suffix, e.g. `300'i16` is
+/// `300 as i16`, the value must be in the range of the type, so `300'i8` is an error. A float
+/// suffix makes a float, e.g. `1'f64`
+fn integer_with_suffix(
+    location: Location,
+    num: &str,
+    literal: &str,
+    suffix: &str,
+    negative: bool,
+) -> Result<Expr> {
+    let magnitude = match split_radix(num) {
+        Some((digits, radix)) => u64::from_str_radix(digits, radix),
+        None => num.parse::<u64>(),
+    };
+    let bits = match suffix {
+        "int" | "i64" => 64,
+        "i32" => 32,
+        "i16" => 16,
+        "i8" => 8,
+        // elz has no unsigned integer type
+        "u64" | "u32" | "u16" | "u8" => {
+            return Err(ParseError::unsigned_suffix(
+                &location,
+                literal.to_string(),
+                suffix.to_string(),
+            ))
+        }
+        _ => {
+            let f = match (magnitude, split_radix(num)) {
+                (Ok(n), _) => n as f64,
+                // too large for `u64` but still a float, e.g. `99999999999999999999'f64`
+                (Err(_), None) => num.parse::<f64>().unwrap(),
+                (Err(_), Some(_)) => {
+                    return Err(ParseError::integer_too_large(
+                        &location,
+                        literal.to_string(),
+                    ))
+                }
+            };
+            let f = if negative { -f } else { f };
+            return float_with_suffix(location, f, literal, Some(suffix));
+        }
+    };
+    let value = match magnitude {
+        Ok(n) if negative => -(n as i128),
+        Ok(n) => n as i128,
+        Err(_) => {
+            return Err(ParseError::integer_too_large(
+                &location,
+                literal.to_string(),
+            ))
+        }
+    };
+    let min = -(1i128 << (bits - 1));
+    let max = (1i128 << (bits - 1)) - 1;
+    if value < min || value > max {
+        let literal = if negative {
+            format!("-{}", literal)
+        } else {
+            literal.to_string()
+        };
+        return Err(ParseError::integer_out_of_range(
+            &location,
+            literal,
+            suffix.to_string(),
+        ));
+    }
+    let expr = Expr::int(location.clone(), value as i64);
+    if bits == 64 {
+        Ok(expr)
+    } else {
+        Ok(Expr::cast(location, expr, ParsedType::type_name(suffix)))
+    }
+}
+
+/// float_with_suffix makes a float literal, the suffix must be a float type, e.g. `1.5'f32`, an
+/// integer literal can have a float suffix too, e.g. `1'f64`
+fn float_with_suffix(
+    location: Location,
+    f: f64,
+    literal: &str,
+    suffix: Option<&str>,
+) -> Result<Expr> {
+    match suffix {
+        None | Some("f64") => Ok(Expr::f64(location, f)),
+        Some("f32") => Ok(Expr::cast(
+            location.clone(),
+            Expr::f64(location, f),
+            ParsedType::type_name("f32"),
+        )),
+        Some(suffix) => Err(ParseError::invalid_suffix(
+            &location,
+            literal.to_string(),
+            suffix.to_string(),
+        )),
+    }
+}
+
+/// split_suffix splits the type suffix from a number literal, e.g. `300'i8` is `("300", Some("i8"))`
+fn split_suffix(literal: &str) -> (&str, Option<&str>) {
+    match literal.find('\'') {
+        Some(i) => (&literal[..i], Some(&literal[i + 1..])),
+        None => (literal, None),
+    }
+}
+
+/// split_radix returns digits and radix of prefixed integer literal, e.g. `0xFF` is `("FF", 16)`
+fn split_radix(literal: &str) -> Option<(&str, u32)> {
+    match literal.get(..2) {
+        Some("0x") | Some("0X") => Some((&literal[2..], 16)),
+        Some("0o") | Some("0O") => Some((&literal[2..], 8)),
+        Some("0b") | Some("0B") => Some((&literal[2..], 2)),
+        _ => None,
+    }
+}
+
+/// infer_type gets the type of a literal, e.g. `int` of `1` and `-1`, other expressions must have
+/// a type annotation
+fn infer_type(expr: &Expr) -> Option<ParsedType> {
+    let name = match &expr.value {
+        ExprVariant::Int(_) => "int",
+        ExprVariant::F64(_) => "f64",
+        ExprVariant::Bool(_) => "bool",
+        ExprVariant::Char(_) => "char",
+        ExprVariant::String(_) => "string",
+        ExprVariant::Unary(_, e) => return infer_type(e),
+        _ => return None,
+    };
+    Some(ParsedType::type_name(name))
+}
+
+fn is_right_associative(op: Token) -> bool {
+    match op.tk_type() {
+        // `2 ^ 3 ^ 2` is `2 ^ (3 ^ 2)`
+        TkType::Caret => true,
+        _ => false,
+    }
 }
 
 fn precedence(op: Token) -> u64 {
     match op.tk_type() {
-        TkType::Plus => 2,
+        TkType::OrOr => 1,
+        TkType::AndAnd => 2,
+        TkType::EqualEqual
+        | TkType::NotEqual
+        | TkType::LessThan
+        | TkType::LessThanOrEqual
+        | TkType::GreaterThan
+        | TkType::GreaterThanOrEqual => 3,
+        TkType::Plus | TkType::Minus => 4,
+        TkType::Multiple | TkType::Divide | TkType::Percent => 5,
+        TkType::Caret => 6,
         _ => 0,
     }
 }
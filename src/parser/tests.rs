@@ -41,8 +41,8 @@ fn parse_function_with_expression_body() {
             None,
             "add",
             vec![
-                Parameter::new("x", ParsedType::type_name("int")),
-                Parameter::new("y", ParsedType::type_name("int")),
+                Parameter::new(Location::from(1, 4), "x", ParsedType::type_name("int")),
+                Parameter::new(Location::from(1, 12), "y", ParsedType::type_name("int")),
             ],
             ParsedType::type_name("int"),
             Body::Expr(Expr::binary(
@@ -97,6 +97,18 @@ fn parse_variable_define() {
     )
 }
 
+#[test]
+fn parse_variable_define_without_type() {
+    let mut parser = Parser::new("", "x := 1;");
+    let var = parser.parse_variable(None).unwrap();
+    assert_eq!(var.typ, ParsedType::type_name("int"));
+    let mut parser = Parser::new("", "x := -1.5;");
+    let var = parser.parse_variable(None).unwrap();
+    assert_eq!(var.typ, ParsedType::type_name("f64"));
+    let mut parser = Parser::new("", "x := foo();");
+    assert_eq!(parser.parse_variable(None).is_err(), true);
+}
+
 #[test]
 fn parse_variable_define_with_list_value() {
     let code = "\
@@ -125,6 +137,121 @@ fn parse_variable_define_with_list_value() {
     )
 }
 
+#[test]
+fn parse_list_repeat() {
+    let mut parser = Parser::new("", "[0; 4]");
+    let zero = Expr::int(Location::from(1, 1), 0);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::list_repeat(Location::from(1, 0), zero, 4)
+    );
+
+    let mut parser = Parser::new("", "[0; n]");
+    assert_eq!(parser.parse_expression(None, None).is_err(), true);
+
+    let mut parser = Parser::new("", "[0; 99999999999999999999]");
+    let err = parser.parse_expression(None, None).unwrap_err();
+    assert_eq!(err.location(), Location::from(1, 4));
+    assert_eq!(err.message(), "repeat count too large");
+}
+
+#[test]
+fn parse_radix_integer() {
+    let mut parser = Parser::new("", "0xFF");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::int(Location::from(1, 0), 255)
+    );
+    let mut parser = Parser::new("", "0xFFFFFFFFFFFFFFFF");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::int(Location::from(1, 0), -1)
+    );
+    let mut parser = Parser::new("", "0x10000000000000000");
+    assert_eq!(parser.parse_expression(None, None).is_err(), true);
+    let mut parser = Parser::new("", "0x_");
+    assert_eq!(parser.parse_expression(None, None).is_err(), true);
+}
+
+#[test]
+fn parse_integer_with_suffix() {
+    let location = Location::from(1, 0);
+    let parse = |code| Parser::new("", code).parse_expression(None, None);
+    assert_eq!(
+        parse("0xFF_FF'i32").unwrap(),
+        Expr::cast(
+            location.clone(),
+            Expr::int(location.clone(), 65535),
+            ParsedType::type_name("i32")
+        )
+    );
+    assert_eq!(parse("3'int").unwrap(), Expr::int(location.clone(), 3));
+    assert_eq!(parse("3'f64").unwrap(), Expr::f64(location.clone(), 3.0));
+    assert_eq!(
+        parse("1_000.5e3'f64").unwrap(),
+        Expr::f64(location.clone(), 1_000_500.0)
+    );
+    assert_eq!(
+        parse("1.5'f32").unwrap(),
+        Expr::cast(
+            location.clone(),
+            Expr::f64(location.clone(), 1.5),
+            ParsedType::type_name("f32")
+        )
+    );
+    let err = parse("0xFF_FF'u16").unwrap_err();
+    assert_eq!(err.message(), "unsigned suffix");
+    assert_eq!(
+        err.to_string(),
+        ":1:0 unsigned integer type `u16` of number literal `0xFF_FF'u16` is not supported, use a signed type"
+    );
+    assert_eq!(parse("1'u8").unwrap_err().message(), "unsigned suffix");
+    assert_eq!(parse("1.5'i32").unwrap_err().message(), "invalid suffix");
+    assert_eq!(
+        parse("99999999999999999999'i64").unwrap_err().message(),
+        "integer too large"
+    );
+}
+
+#[test]
+fn integer_suffix_checks_range() {
+    let location = Location::from(1, 0);
+    let parse = |code| Parser::new("", code).parse_expression(None, None);
+    let i8_of = |i| {
+        Expr::cast(
+            location.clone(),
+            Expr::int(location.clone(), i),
+            ParsedType::type_name("i8"),
+        )
+    };
+    assert_eq!(parse("-5'i8").unwrap(), i8_of(-5));
+    assert_eq!(parse("-128'i8").unwrap(), i8_of(-128));
+    assert_eq!(parse("127'i8").unwrap(), i8_of(127));
+    assert_eq!(parse("-5'f64").unwrap(), Expr::f64(location.clone(), -5.0));
+    assert_eq!(
+        parse("-9223372036854775808'int").unwrap(),
+        Expr::int(location.clone(), std::i64::MIN)
+    );
+    for code in [
+        "300'i8",
+        "128'i8",
+        "-129'i8",
+        "0xFF'i8",
+        "9223372036854775808'int",
+    ]
+    .iter()
+    {
+        let err = parse(code).unwrap_err();
+        assert_eq!(err.location(), location);
+        assert_eq!(err.message(), "integer out of range");
+    }
+    assert_eq!(
+        format!("{}", parse("300'i8").unwrap_err())
+            .ends_with("integer literal `300'i8` is out of the range of `i8`"),
+        true
+    );
+}
+
 #[test]
 fn parse_statement_if_block() {
     let code = "if true {} else if false {} else {}";
@@ -150,6 +277,534 @@ fn parse_statement_if_block() {
     )
 }
 
+#[test]
+fn parse_statement_loop_block() {
+    let code = "loop { break; }";
+
+    let mut parser = Parser::new("", code);
+
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::loop_block(
+            Location::from(1, 0),
+            Block::from(
+                Location::from(1, 5),
+                vec![Statement::break_stmt(Location::from(1, 7))]
+            )
+        )
+    )
+}
+
+#[test]
+fn parse_statement_while_block() {
+    let code = "while a < 1 {}";
+
+    let mut parser = Parser::new("", code);
+
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::while_block(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 6),
+                Expr::identifier(Location::from(1, 6), "a"),
+                Expr::int(Location::from(1, 10), 1),
+                Operator::LessThan
+            ),
+            Block::new(Location::from(1, 12))
+        )
+    )
+}
+
+#[test]
+fn parse_statement_while_block_after_identifier() {
+    let code = "while a < n { foo(); }";
+
+    let mut parser = Parser::new("", code);
+
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::while_block(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 6),
+                Expr::identifier(Location::from(1, 6), "a"),
+                Expr::identifier(Location::from(1, 10), "n"),
+                Operator::LessThan
+            ),
+            Block::from(
+                Location::from(1, 12),
+                vec![Statement::expression(
+                    Location::from(1, 14),
+                    Expr::func_call(
+                        Location::from(1, 14),
+                        Expr::identifier(Location::from(1, 14), "foo"),
+                        vec![]
+                    )
+                )]
+            )
+        )
+    )
+}
+
+#[test]
+fn parse_statement_mutable_variable() {
+    let code = "mut x: int = 1;";
+
+    let mut parser = Parser::new("", code);
+
+    let mut var = Variable::new(
+        Location::from(1, 4),
+        None,
+        "x",
+        ParsedType::type_name("int"),
+        Expr::int(Location::from(1, 13), 1),
+    );
+    var.mutable = true;
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::variable(Location::from(1, 0), var)
+    )
+}
+
+#[test]
+fn parse_statement_assign_in_while_block() {
+    let code = "while i < n { i = i + 1; }";
+
+    let mut parser = Parser::new("", code);
+
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::while_block(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 6),
+                Expr::identifier(Location::from(1, 6), "i"),
+                Expr::identifier(Location::from(1, 10), "n"),
+                Operator::LessThan
+            ),
+            Block::from(
+                Location::from(1, 12),
+                vec![Statement::assign(
+                    Location::from(1, 14),
+                    "i",
+                    Expr::binary(
+                        Location::from(1, 18),
+                        Expr::identifier(Location::from(1, 18), "i"),
+                        Expr::int(Location::from(1, 22), 1),
+                        Operator::Plus
+                    )
+                )]
+            )
+        )
+    )
+}
+
+#[test]
+fn parse_statement_destructure() {
+    let code = "(x, y): (int, f64) = (1, 2.0);";
+
+    let mut parser = Parser::new("", code);
+
+    assert_eq!(
+        parser.parse_statement().unwrap(),
+        Statement::destructure(
+            Location::from(1, 0),
+            vec!["x".to_string(), "y".to_string()],
+            ParsedType::tuple(vec![
+                ParsedType::type_name("int"),
+                ParsedType::type_name("f64")
+            ]),
+            Expr::tuple(
+                Location::from(1, 21),
+                vec![
+                    Expr::int(Location::from(1, 22), 1),
+                    Expr::f64(Location::from(1, 25), 2.0)
+                ]
+            )
+        )
+    )
+}
+
+#[test]
+fn final_expression_is_returned() {
+    let code = "f(): int { foo(); x + 1 }";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_function(None).unwrap(),
+        Function::new(
+            Location::from(1, 0),
+            None,
+            "f",
+            vec![],
+            ParsedType::type_name("int"),
+            Body::Block(Block::from(
+                Location::from(1, 9),
+                vec![
+                    Statement::expression(
+                        Location::from(1, 11),
+                        Expr::func_call(
+                            Location::from(1, 11),
+                            Expr::identifier(Location::from(1, 11), "foo"),
+                            vec![]
+                        )
+                    ),
+                    Statement::return_stmt(
+                        Location::from(1, 18),
+                        Some(Expr::binary(
+                            Location::from(1, 18),
+                            Expr::identifier(Location::from(1, 18), "x"),
+                            Expr::int(Location::from(1, 22), 1),
+                            Operator::Plus
+                        ))
+                    ),
+                ]
+            ))
+        )
+    )
+}
+
+#[test]
+fn final_expression_of_nested_block_is_not_returned() {
+    let mut parser = Parser::new("", "f(): int { loop { 1 } }");
+    let err = parser.parse_function(None).unwrap_err();
+    assert_eq!(err.location(), Location::from(1, 20));
+    let mut parser = Parser::new("", "{ x + 1 }");
+    assert_eq!(parser.parse_block().is_err(), true);
+}
+
+#[test]
+fn parse_unary_plus() {
+    let code = "+1 + +a";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::unary(
+                Location::from(1, 0),
+                UnaryOperator::Plus,
+                Expr::int(Location::from(1, 1), 1)
+            ),
+            Expr::unary(
+                Location::from(1, 5),
+                UnaryOperator::Plus,
+                Expr::identifier(Location::from(1, 6), "a")
+            ),
+            Operator::Plus
+        )
+    )
+}
+
+#[test]
+fn parse_unary_minus() {
+    let code = "-a + b";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::unary(
+                Location::from(1, 0),
+                UnaryOperator::Minus,
+                Expr::identifier(Location::from(1, 1), "a")
+            ),
+            Expr::identifier(Location::from(1, 5), "b"),
+            Operator::Plus
+        )
+    )
+}
+
+#[test]
+fn parse_unary_not() {
+    let code = "!a && b";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::unary(
+                Location::from(1, 0),
+                UnaryOperator::Not,
+                Expr::identifier(Location::from(1, 1), "a")
+            ),
+            Expr::identifier(Location::from(1, 6), "b"),
+            Operator::And
+        )
+    )
+}
+
+#[test]
+fn parse_cast() {
+    let code = "-x as f64";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::unary(
+            Location::from(1, 0),
+            UnaryOperator::Minus,
+            Expr::cast(
+                Location::from(1, 3),
+                Expr::identifier(Location::from(1, 1), "x"),
+                ParsedType::type_name("f64")
+            )
+        )
+    )
+}
+
+#[test]
+fn parse_match() {
+    let code = "match x { 1 => a, _ => 0 }";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::match_expr(
+            Location::from(1, 0),
+            Expr::identifier(Location::from(1, 6), "x"),
+            vec![
+                MatchArm::new(
+                    Some(Expr::int(Location::from(1, 10), 1)),
+                    Expr::identifier(Location::from(1, 15), "a")
+                ),
+                MatchArm::new(None, Expr::int(Location::from(1, 23), 0)),
+            ]
+        )
+    )
+}
+
+#[test]
+fn not_equal_binds_looser_than_plus() {
+    let code = "a + 1 != b";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 0),
+                Expr::identifier(Location::from(1, 0), "a"),
+                Expr::int(Location::from(1, 4), 1),
+                Operator::Plus
+            ),
+            Expr::identifier(Location::from(1, 9), "b"),
+            Operator::NotEqual
+        )
+    )
+}
+
+#[test]
+fn and_binds_tighter_than_or() {
+    let code = "a || b && c == 1";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::identifier(Location::from(1, 0), "a"),
+            Expr::binary(
+                Location::from(1, 5),
+                Expr::identifier(Location::from(1, 5), "b"),
+                Expr::binary(
+                    Location::from(1, 10),
+                    Expr::identifier(Location::from(1, 10), "c"),
+                    Expr::int(Location::from(1, 15), 1),
+                    Operator::Equal
+                ),
+                Operator::And
+            ),
+            Operator::Or
+        )
+    )
+}
+
+#[test]
+fn pow_is_right_associative() {
+    let code = "1 + 2 ^ 3 ^ 4";
+
+    let mut parser = Parser::new("", code);
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::int(Location::from(1, 0), 1),
+            Expr::binary(
+                Location::from(1, 4),
+                Expr::int(Location::from(1, 4), 2),
+                Expr::binary(
+                    Location::from(1, 8),
+                    Expr::int(Location::from(1, 8), 3),
+                    Expr::int(Location::from(1, 12), 4),
+                    Operator::Pow
+                ),
+                Operator::Pow
+            ),
+            Operator::Plus
+        )
+    )
+}
+
+#[test]
+fn multiply_binds_tighter_than_plus() {
+    let mut parser = Parser::new("", "1 + 2 * 3");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::int(Location::from(1, 0), 1),
+            Expr::binary(
+                Location::from(1, 4),
+                Expr::int(Location::from(1, 4), 2),
+                Expr::int(Location::from(1, 8), 3),
+                Operator::Multiply
+            ),
+            Operator::Plus
+        )
+    )
+}
+
+#[test]
+fn minus_is_left_associative_and_parenthesized() {
+    let mut parser = Parser::new("", "a - b - (c - d) < 1");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 0),
+                Expr::binary(
+                    Location::from(1, 0),
+                    Expr::identifier(Location::from(1, 0), "a"),
+                    Expr::identifier(Location::from(1, 4), "b"),
+                    Operator::Minus
+                ),
+                Expr::binary(
+                    Location::from(1, 9),
+                    Expr::identifier(Location::from(1, 9), "c"),
+                    Expr::identifier(Location::from(1, 13), "d"),
+                    Operator::Minus
+                ),
+                Operator::Minus
+            ),
+            Expr::int(Location::from(1, 18), 1),
+            Operator::LessThan
+        )
+    );
+    let mut parser = Parser::new("", "(1 + 2");
+    assert_eq!(parser.parse_expression(None, None).is_err(), true);
+}
+
+#[test]
+fn parse_index() {
+    let mut parser = Parser::new("", "xs[i + 1][0]");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::index(
+            Location::from(1, 9),
+            Expr::index(
+                Location::from(1, 2),
+                Expr::identifier(Location::from(1, 0), "xs"),
+                Expr::binary(
+                    Location::from(1, 3),
+                    Expr::identifier(Location::from(1, 3), "i"),
+                    Expr::int(Location::from(1, 7), 1),
+                    Operator::Plus
+                )
+            ),
+            Expr::int(Location::from(1, 10), 0)
+        )
+    );
+}
+
+#[test]
+fn parse_class_construction_with_named_fields() {
+    let mut parser = Parser::new("", "Point {y: 2, x: 1}");
+    let mut field_inits = HashMap::new();
+    field_inits.insert("x".to_string(), Expr::int(Location::from(1, 16), 1));
+    field_inits.insert("y".to_string(), Expr::int(Location::from(1, 10), 2));
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::class_construction(Location::from(1, 0), "Point", field_inits)
+    );
+    let mut parser = Parser::new("", "Point {x: 1, x: 2}");
+    assert_eq!(parser.parse_expression(None, None).is_err(), true);
+}
+
+#[test]
+fn function_call_in_binary_expression() {
+    let mut parser = Parser::new("", "f(1) + g()");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::func_call(
+                Location::from(1, 0),
+                Expr::identifier(Location::from(1, 0), "f"),
+                vec![Argument::new(
+                    Location::from(1, 2),
+                    None,
+                    Expr::int(Location::from(1, 2), 1)
+                )]
+            ),
+            Expr::func_call(
+                Location::from(1, 7),
+                Expr::identifier(Location::from(1, 7), "g"),
+                vec![]
+            ),
+            Operator::Plus
+        )
+    );
+    let mut parser = Parser::new("", "f(1,)");
+    let err = parser.parse_expression(None, None).unwrap_err();
+    assert_eq!(err.location(), Location::from(1, 3));
+    assert_eq!(err.message(), "trailing comma");
+}
+
+#[test]
+fn diff_expr_pinpoints_literal_value() {
+    let mut parser = Parser::new("", "1 + 2 + a");
+    let actual = parser.parse_expression(None, None).unwrap();
+    let expected = Expr::binary(
+        Location::from(1, 0),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::int(Location::from(1, 0), 1),
+            Expr::int(Location::from(1, 4), 3),
+            Operator::Plus,
+        ),
+        Expr::identifier(Location::from(1, 8), "a"),
+        Operator::Plus,
+    );
+    assert_eq!(
+        diff(&expected, &actual),
+        Some("expr.lhs.rhs: expected `Int(3)` but got `Int(2)`".to_string())
+    );
+}
+
+#[test]
+fn diff_expr_pinpoints_node_kind() {
+    let mut parser = Parser::new("", "+a");
+    let actual = parser.parse_expression(None, None).unwrap();
+    let expected = Expr::binary(
+        Location::from(1, 0),
+        Expr::int(Location::from(1, 0), 1),
+        Expr::identifier(Location::from(1, 1), "a"),
+        Operator::Plus,
+    );
+    assert_eq!(
+        diff(&expected, &actual),
+        Some("expr: expected Binary node but got Unary node".to_string())
+    );
+    assert_eq!(diff(&actual, &actual), None);
+}
+
 #[test]
 fn parse_expr_string() {
     let code = "\
@@ -174,6 +829,63 @@ fn parse_expr_string() {
     assert_eq!(s, expected)
 }
 
+#[test]
+fn parse_string_escape_sequences() {
+    let mut parser = Parser::new("", "\"a\\nb\\t\\r\\0\"");
+    assert_eq!(
+        parser.parse_string().unwrap(),
+        Expr::string(Location::from(1, 0), "a\nb\t\r\0")
+    );
+    let mut parser = Parser::new("", "\"\\{a\\}\"");
+    assert_eq!(
+        parser.parse_string().unwrap(),
+        Expr::string(Location::from(1, 0), "{a}")
+    );
+}
+
+#[test]
+fn parse_char() {
+    let mut parser = Parser::new("", "'λ'");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::char(Location::from(1, 0), 'λ')
+    );
+    let mut parser = Parser::new("", "'\\n'");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::char(Location::from(1, 0), '\n')
+    );
+    let mut parser = Parser::new("", "'\\x41'");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::char(Location::from(1, 0), 'A')
+    );
+    let mut parser = Parser::new("", "'\\u{1F600}'");
+    assert_eq!(
+        parser.parse_expression(None, None).unwrap(),
+        Expr::char(Location::from(1, 0), '😀')
+    );
+}
+
+#[test]
+fn parse_unicode_escape_in_string() {
+    let mut parser = Parser::new("", "\"a\\x41\\u{3bb}{b}\\u{1F600}\"");
+    assert_eq!(
+        parser.parse_string().unwrap(),
+        Expr::binary(
+            Location::from(1, 0),
+            Expr::binary(
+                Location::from(1, 0),
+                Expr::string(Location::from(1, 0), "aAλ"),
+                Expr::identifier(Location::from(1, 0), "b"),
+                Operator::Plus
+            ),
+            Expr::string(Location::from(1, 0), "😀"),
+            Operator::Plus
+        )
+    );
+}
+
 #[test]
 fn parse_expr_class_construction() {
     let code = "Car { name: \"\", price: 10000 }";
@@ -220,14 +932,22 @@ fn parse_class() {
                     Location::from(3, 2),
                     None,
                     "new",
-                    vec![Parameter::new("name", ParsedType::type_name("string"))],
+                    vec![Parameter::new(
+                        Location::from(3, 6),
+                        "name",
+                        ParsedType::type_name("string")
+                    )],
                     ParsedType::type_name("Car"),
                 )),
                 ClassMember::Method(Function::new_declaration(
                     Location::from(4, 0),
                     None,
                     "bar",
-                    vec![Parameter::new("i", ParsedType::type_name("int"))],
+                    vec![Parameter::new(
+                        Location::from(4, 4),
+                        "i",
+                        ParsedType::type_name("int")
+                    )],
                     ParsedType::type_name("void"),
                 )),
             ]
@@ -320,6 +1040,14 @@ fn import() {
     )
 }
 
+#[test]
+fn import_without_path_or_close_paren() {
+    let mut parser = Parser::new("", "import ( bar )");
+    assert_eq!(parser.parse_import().is_err(), true);
+    let mut parser = Parser::new("", "import foo ( bar");
+    assert_eq!(parser.parse_import().is_err(), true);
+}
+
 #[test]
 fn parse_tag() {
     let code = "@builtin";
@@ -0,0 +1,148 @@
+use crate::ast::*;
+use crate::constant::{evaluate, Constant};
+use crate::lexer::Location;
+
+#[derive(Debug, PartialEq)]
+pub enum ConstantCondition {
+    /// condition of `if` is always the value
+    If(bool),
+    /// value of `match` is a constant, so only one arm is taken
+    Match,
+}
+
+#[derive(Debug, PartialEq)]
+pub struct ConstantConditionWarning {
+    pub location: Location,
+    pub condition: ConstantCondition,
+}
+
+impl std::fmt::Display for ConstantConditionWarning {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        match self.condition {
+            ConstantCondition::If(true) => {
+                write!(f, "condition is always true, the branch is always taken")
+            }
+            ConstantCondition::If(false) => {
+                write!(f, "condition is always false, the branch is never taken")
+            }
+            ConstantCondition::Match => {
+                write!(f, "matched value is a constant, only one arm is taken")
+            }
+        }
+    }
+}
+
+/// check_conditions is a lint, it reports conditions can be folded to a constant, e.g. `if 1 == 1`
+/// or `match 1 { ... }`, which often be a bug, the branch still compiles
+pub fn check_conditions(module: &Module) -> Vec<ConstantConditionWarning> {
+    let mut warnings = vec![];
+    for top in &module.top_list {
+        match top {
+            TopAst::Function(f) => function(&mut warnings, f),
+            TopAst::Class(c) => {
+                for member in &c.members {
+                    match member {
+                        ClassMember::Method(f) | ClassMember::StaticMethod(f) => {
+                            function(&mut warnings, f)
+                        }
+                        ClassMember::Field(_) => (),
+                    }
+                }
+            }
+            TopAst::Trait(t) => {
+                for member in &t.members {
+                    if let TraitMember::Method(f) = member {
+                        function(&mut warnings, f);
+                    }
+                }
+            }
+            TopAst::Import(_) | TopAst::Variable(_) => (),
+        }
+    }
+    warnings
+}
+
+fn function(warnings: &mut Vec<ConstantConditionWarning>, f: &Function) {
+    match &f.body {
+        Some(Body::Block(b)) => block(warnings, b),
+        Some(Body::Expr(e)) => expr(warnings, e),
+        None => (),
+    }
+}
+
+fn block(warnings: &mut Vec<ConstantConditionWarning>, b: &Block) {
+    for stmt in &b.statements {
+        match &stmt.value {
+            StatementVariant::IfBlock {
+                clauses,
+                else_block,
+            } => {
+                for (condition, then_block) in clauses {
+                    if let Some(Constant::Bool(value)) = evaluate(condition) {
+                        warnings.push(ConstantConditionWarning {
+                            location: condition.location.clone(),
+                            condition: ConstantCondition::If(value),
+                        });
+                    }
+                    expr(warnings, condition);
+                    block(warnings, then_block);
+                }
+                block(warnings, else_block);
+            }
+            StatementVariant::Loop(body) => block(warnings, body),
+            StatementVariant::While(condition, body) => {
+                expr(warnings, condition);
+                block(warnings, body)
+            }
+            StatementVariant::Return(Some(e))
+            | StatementVariant::Expression(e)
+            | StatementVariant::Assign(_, e)
+            | StatementVariant::Destructure(_, _, e) => expr(warnings, e),
+            StatementVariant::Variable(v) => expr(warnings, &v.expr),
+            StatementVariant::Return(None)
+            | StatementVariant::Break
+            | StatementVariant::Continue => (),
+        }
+    }
+}
+
+/// expr looks for `match` in an expression, e.g. `f(match 1 { 1 => a, _ => b })`
+fn expr(warnings: &mut Vec<ConstantConditionWarning>, e: &Expr) {
+    use ExprVariant::*;
+    match &e.value {
+        Match(value, arms) => {
+            if evaluate(value).is_some() {
+                warnings.push(ConstantConditionWarning {
+                    location: value.location.clone(),
+                    condition: ConstantCondition::Match,
+                });
+            }
+            expr(warnings, value);
+            for arm in arms {
+                expr(warnings, &arm.expr);
+            }
+        }
+        Binary(l, r, _) | Index(l, r) => {
+            expr(warnings, l);
+            expr(warnings, r);
+        }
+        Unary(_, e) | Cast(e, _) | MemberAccess(e, _) | ListRepeat(e, _) => expr(warnings, e),
+        List(es) | Tuple(es) => {
+            for e in es {
+                expr(warnings, e);
+            }
+        }
+        FuncCall(f, args) => {
+            expr(warnings, f);
+            for arg in args {
+                expr(warnings, &arg.expr);
+            }
+        }
+        ClassConstruction(_, field_inits) => {
+            for e in field_inits.values() {
+                expr(warnings, e);
+            }
+        }
+        F64(_) | Int(_) | Bool(_) | Char(_) | String(_) | Identifier(_) => (),
+    }
+}
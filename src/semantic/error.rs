@@ -1,4 +1,5 @@
 use super::type_checker::Type;
+use crate::ast::{Operator, UnaryOperator};
 use crate::lexer::Location;
 use thiserror::Error;
 
@@ -13,16 +14,35 @@ pub struct SemanticError {
 
 #[derive(Debug, Error)]
 enum SemanticErrorVariant {
-    #[error("name: `{}` be redefined", .0)]
-    NameRedefined(String),
+    #[error("name: `{}` be redefined, already defined at {}", .name, .previous_definition)]
+    NameRedefined {
+        name: String,
+        previous_definition: Location,
+    },
     #[error("type mismatched, expected: `{}` but got: `{}`", .0, .1)]
     TypeMismatched(Type, Type),
+    #[error("cannot assign to immutable variable: `{}`, defined at {}", .name, .definition)]
+    AssignToImmutable { name: String, definition: Location },
     #[error("no variable named: `{}`", .0)]
     NoVariableNamed(String),
     #[error("no type named: `{}`",  .0)]
     NoTypeNamed(String),
     #[error("call on non-function type: `{}`", .0)]
     CallOnNonFunctionType(Type),
+    #[error("cannot index into a value of type: `{}`", .0)]
+    CannotIndex(Type),
+    #[error("cannot cast a value of type: `{}` to `{}`", .0, .1)]
+    CannotCast(Type, Type),
+    #[error("cannot match on a value of type: `{}`, only `int`, `i32` and `char` can be matched", .0)]
+    CannotMatch(Type),
+    #[error("pattern must be a constant of type: `{}`", .0)]
+    InvalidPattern(Type),
+    #[error("unreachable match arm, the pattern is already matched")]
+    UnreachableArm,
+    #[error("match on `{}` must have a default arm `_`", .0)]
+    NonExhaustiveMatch(Type),
+    #[error("cannot destructure a value of type: `{}` into {} names", .0, .1)]
+    CannotDestructure(Type, usize),
     #[error("following fields must be inited but haven't: {}", ShowFieldsList(.0.to_vec()))]
     FieldsMissingInit(Vec<String>),
     #[error("cannot use class construction on a non-class type: {}", .0)]
@@ -31,8 +51,18 @@ enum SemanticErrorVariant {
     CannotUseClassConstructionOutOfClass(),
     #[error("only trait can be super type, but got: {}", .got_type)]
     OnlyTraitCanBeSuperType { got_type: Type },
+    #[error("function `{}` must return a value of type: `{}` on every path", .0, .1)]
+    MissingReturn(String, Type),
+    #[error("global initializer must be a constant")]
+    GlobalInitializerNotConstant,
+    #[error("global list repeats a non-zero element {} times, at most {} is allowed", .0, .1)]
+    GlobalListTooLong(usize, usize),
     #[error("dead code after return statement")]
     DeadCodeAfterReturnStatement,
+    #[error("unreachable statement, the execution never reaches here")]
+    UnreachableStatement,
+    #[error("`{}` outside of a loop", .0)]
+    OutsideOfLoop(String),
     #[error("redefined member `{}` in class `{}`, already defined at {}", .member_name, .class_name, .previous_definition)]
     RedefinedMember {
         member_name: String,
@@ -48,6 +78,22 @@ enum SemanticErrorVariant {
     NonExternFunctionMustHaveBody { function_name: String },
     #[error("no module named: `{}`", .module_name)]
     NoModuleNamed { module_name: String },
+    #[error("cannot apply unary operator `{}` on type: `{}`", .op, .typ)]
+    CannotApplyUnaryOperator { op: UnaryOperator, typ: Type },
+    #[error("method `{}`: expected {} but got {}", .method_name, .expected, .actual)]
+    MethodSignatureMismatched {
+        method_name: String,
+        expected: Type,
+        actual: Type,
+    },
+    #[error("cannot apply binary operator `{}` on type: `{}` and `{}`", .op, .lhs, .rhs)]
+    CannotApplyBinaryOperator { op: Operator, lhs: Type, rhs: Type },
+    #[error("call to `{}`: expected {} arguments but got {}", .callee, .expected, .actual)]
+    ArgumentCountMismatched {
+        callee: String,
+        expected: usize,
+        actual: usize,
+    },
 }
 
 impl SemanticError {
@@ -83,10 +129,17 @@ impl SemanticError {
             },
         )
     }
-    pub fn name_redefined<T: ToString>(location: &Location, name: T) -> SemanticError {
+    pub fn name_redefined<T: ToString>(
+        location: &Location,
+        name: T,
+        previous_definition: &Location,
+    ) -> SemanticError {
         SemanticError::new(
             location,
-            SemanticErrorVariant::NameRedefined(name.to_string()),
+            SemanticErrorVariant::NameRedefined {
+                name: name.to_string(),
+                previous_definition: previous_definition.clone(),
+            },
         )
     }
     pub fn type_mismatched(location: &Location, expected: &Type, actual: &Type) -> SemanticError {
@@ -95,6 +148,19 @@ impl SemanticError {
             SemanticErrorVariant::TypeMismatched(expected.clone(), actual.clone()),
         )
     }
+    pub fn assign_to_immutable(
+        location: &Location,
+        name: &str,
+        definition: &Location,
+    ) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::AssignToImmutable {
+                name: name.to_string(),
+                definition: definition.clone(),
+            },
+        )
+    }
     pub fn no_variable(location: &Location, name: &str) -> SemanticError {
         SemanticError::new(
             location,
@@ -110,6 +176,36 @@ impl SemanticError {
     pub fn call_on_non_function_type(location: &Location, typ: Type) -> SemanticError {
         SemanticError::new(location, SemanticErrorVariant::CallOnNonFunctionType(typ))
     }
+    pub fn cannot_index(location: &Location, typ: Type) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::CannotIndex(typ))
+    }
+    pub fn cannot_cast(location: &Location, from: Type, to: Type) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::CannotCast(from, to))
+    }
+    pub fn cannot_match(location: &Location, typ: Type) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::CannotMatch(typ))
+    }
+    pub fn invalid_pattern(location: &Location, typ: Type) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::InvalidPattern(typ))
+    }
+    pub fn unreachable_arm(location: &Location) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::UnreachableArm)
+    }
+    pub fn non_exhaustive_match(location: &Location, typ: Type) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::NonExhaustiveMatch(typ))
+    }
+    pub fn cannot_destructure(location: &Location, typ: Type, names: usize) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::CannotDestructure(typ, names),
+        )
+    }
+    pub fn missing_return(location: &Location, function_name: &str, typ: Type) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::MissingReturn(function_name.to_string(), typ),
+        )
+    }
     pub fn fields_missing_init(location: &Location, fields: Vec<String>) -> SemanticError {
         SemanticError::new(location, SemanticErrorVariant::FieldsMissingInit(fields))
     }
@@ -133,9 +229,27 @@ impl SemanticError {
             },
         )
     }
+    pub fn global_initializer_not_constant(location: &Location) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::GlobalInitializerNotConstant)
+    }
+    pub fn global_list_too_long(location: &Location, count: usize, limit: usize) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::GlobalListTooLong(count, limit),
+        )
+    }
     pub fn dead_code_after_return_statement(location: &Location) -> SemanticError {
         SemanticError::new(location, SemanticErrorVariant::DeadCodeAfterReturnStatement)
     }
+    pub fn unreachable_statement(location: &Location) -> SemanticError {
+        SemanticError::new(location, SemanticErrorVariant::UnreachableStatement)
+    }
+    pub fn outside_of_loop(location: &Location, keyword: &str) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::OutsideOfLoop(keyword.to_string()),
+        )
+    }
     pub fn redefined_member(
         location: &Location,
         member_name: String,
@@ -164,6 +278,64 @@ impl SemanticError {
             },
         )
     }
+    pub fn cannot_apply_unary_operator(
+        location: &Location,
+        op: &UnaryOperator,
+        typ: &Type,
+    ) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::CannotApplyUnaryOperator {
+                op: op.clone(),
+                typ: typ.clone(),
+            },
+        )
+    }
+    pub fn method_signature_mismatched<T: ToString>(
+        location: &Location,
+        method_name: T,
+        expected: &Type,
+        actual: &Type,
+    ) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::MethodSignatureMismatched {
+                method_name: method_name.to_string(),
+                expected: expected.clone(),
+                actual: actual.clone(),
+            },
+        )
+    }
+    pub fn argument_count_mismatched(
+        location: &Location,
+        callee: String,
+        expected: usize,
+        actual: usize,
+    ) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::ArgumentCountMismatched {
+                callee,
+                expected,
+                actual,
+            },
+        )
+    }
+    pub fn cannot_apply_binary_operator(
+        location: &Location,
+        op: &Operator,
+        lhs: &Type,
+        rhs: &Type,
+    ) -> SemanticError {
+        SemanticError::new(
+            location,
+            SemanticErrorVariant::CannotApplyBinaryOperator {
+                op: op.clone(),
+                lhs: lhs.clone(),
+                rhs: rhs.clone(),
+            },
+        )
+    }
 }
 
 struct ShowFieldsList(Vec<String>);
@@ -1,7 +1,10 @@
 use crate::ast::*;
+use crate::constant;
 use crate::lexer::Location;
 
+pub mod condition;
 mod error;
+pub mod naming;
 mod tag;
 mod type_checker;
 
@@ -10,6 +13,10 @@ use std::collections::HashMap;
 use tag::SemanticTag;
 use type_checker::{Type, TypeEnv};
 
+/// MAX_GLOBAL_LIST_REPEAT is the most elements `[v; n]` repeats in a global initializer, unless
+/// `v` is zero, a zero list takes no space in the binary
+pub const MAX_GLOBAL_LIST_REPEAT: usize = 65536;
+
 pub struct SemanticChecker {
     top_env: TypeEnv,
 }
@@ -65,6 +72,21 @@ impl SemanticChecker {
         module_envs: &mut HashMap<String, TypeEnv>,
     ) -> Result<()> {
         let module_env = module_envs.get_mut(&module.name).unwrap();
+        // trait must be ready before class, since class would check its super traits
+        for top in &module.top_list {
+            match &top {
+                TopAst::Trait(t) => {
+                    let typ = module_env.new_trait(t)?;
+                    self.top_env.add_type(
+                        &t.location,
+                        &with_module_name(module.name.clone(), &t.name),
+                        typ.clone(),
+                    )?;
+                    module_env.add_type(&t.location, &t.name, typ)?;
+                }
+                _ => (),
+            }
+        }
         for top in &module.top_list {
             use TopAst::*;
             match &top {
@@ -155,11 +177,30 @@ impl SemanticChecker {
             match &top {
                 Import(_) => (),
                 Variable(v) => {
-                    let typ = module_env.type_of_expr(&v.expr)?;
+                    let var_def_typ = module_env.from(&v.typ)?;
+                    let typ = module_env.type_of_expr_in_context(&v.expr, &var_def_typ)?;
                     // show where error happened
                     // we are unifying <expr> and <type>, so <expr> location is better than
                     // variable define statement location
-                    module_env.unify(&v.expr.location, &module_env.from(&v.typ)?, &typ)?
+                    module_env.unify(&v.expr.location, &var_def_typ, &typ)?;
+                    // a global is initialized by LLVM, there is no code runs before `main`
+                    if constant::evaluate(&v.expr).is_none() && !constant::is_constant_list(&v.expr)
+                    {
+                        return Err(SemanticError::global_initializer_not_constant(
+                            &v.expr.location,
+                        ));
+                    }
+                    // each element of a global list is written into the binary, except all zero
+                    if let ExprVariant::ListRepeat(e, count) = &v.expr.value {
+                        let is_zero = constant::evaluate(e).map_or(false, |c| c.is_zero());
+                        if !is_zero && *count > MAX_GLOBAL_LIST_REPEAT {
+                            return Err(SemanticError::global_list_too_long(
+                                &v.expr.location,
+                                *count,
+                                MAX_GLOBAL_LIST_REPEAT,
+                            ));
+                        }
+                    }
                 }
                 Function(f) => self.check_function_body(&f.location, &f, &module_env)?,
                 Class(c) => {
@@ -184,17 +225,24 @@ impl SemanticChecker {
                                 )?;
                             }
                             ClassMember::Method(method) => {
-                                self.check_function_body(
+                                // fields can be accessed via `self.field` or just `field`,
+                                // parameters and local variables would shadow fields
+                                let mut method_env = TypeEnv::with_parent(&class_type_env);
+                                let class_type =
+                                    class_type_env.lookup_type(&c.location, &c.name)?;
+                                method_env.add_variable(
                                     &method.location,
-                                    &method,
-                                    &class_type_env,
+                                    "self",
+                                    class_type.typ,
                                 )?;
+                                self.check_function_body(&method.location, &method, &method_env)?;
                             }
                             _ => (),
                         }
                     }
                 }
-                Trait(_) => unimplemented!(),
+                // FIXME: check default method body of trait
+                Trait(_) => (),
             }
         }
         Ok(())
@@ -203,18 +251,39 @@ impl SemanticChecker {
     fn check_function_body(&self, location: &Location, f: &Function, env: &TypeEnv) -> Result<()> {
         let return_type = env.from(&f.ret_typ)?;
         let mut type_env = TypeEnv::with_parent(env);
-        for Parameter { name, typ } in &f.parameters {
+        for Parameter {
+            location,
+            name,
+            typ,
+        } in &f.parameters
+        {
+            // report at the parameter, e.g. the second `a` of `foo(a: int, a: int)`
             type_env.add_variable(location, name, type_env.from(typ)?)?;
         }
         match &f.body {
             Some(Body::Expr(e)) => {
-                let e_type = type_env.type_of_expr(e)?;
+                let e_type = type_env.type_of_expr_in_context(e, &return_type)?;
                 type_env.unify(location, &return_type, &e_type)
             }
-            Some(Body::Block(b)) => self.check_block(&type_env, b, &return_type),
+            Some(Body::Block(b)) => {
+                self.check_block(&type_env, b, &return_type, false)?;
+                // a function reaches the end of its body without `return` returns `void`
+                if falls_through(b) {
+                    let location = b.statements.last().map_or(&b.location, |s| &s.location);
+                    let void = type_env.lookup_type(location, "void")?.typ;
+                    if type_env.unify(location, &return_type, &void).is_err() {
+                        return Err(SemanticError::missing_return(
+                            location,
+                            &f.name,
+                            return_type,
+                        ));
+                    }
+                }
+                Ok(())
+            }
             None => {
-                if f.tag.is_extern() {
-                    // extern function declaration don't have body need to check
+                if f.tag.is_extern() || f.tag.is_builtin() {
+                    // extern and builtin function declaration don't have body need to check
                     // e.g.
                     // ```
                     // foo(): void;
@@ -230,77 +299,116 @@ impl SemanticChecker {
         }
     }
 
-    fn check_block(&self, type_env: &TypeEnv, b: &Block, return_type: &Type) -> Result<()> {
+    /// check_block checks statements in the block, `in_loop` is true if the block is in a loop body
+    fn check_block(
+        &self,
+        type_env: &TypeEnv,
+        b: &Block,
+        return_type: &Type,
+        in_loop: bool,
+    ) -> Result<()> {
         let mut type_env = TypeEnv::with_parent(type_env);
-        let location = &b.location;
-        if b.statements.len() == 0 {
-            if type_env
-                .unify(
-                    location,
-                    return_type,
-                    &type_env.lookup_type(location, "void")?.typ,
-                )
-                .is_err()
-            {
-                return Err(SemanticError::dead_code_after_return_statement(location));
+        for (i, stmt) in b.statements.iter().enumerate() {
+            use StatementVariant::*;
+            let location = &stmt.location;
+            // e.g. a statement after `if c { return 1; } else { return 2; }` or `break;`
+            if i > 0 && !statement_falls_through(&b.statements[i - 1]) {
+                return Err(SemanticError::unreachable_statement(location));
             }
-        } else {
-            for (i, stmt) in b.statements.iter().enumerate() {
-                use StatementVariant::*;
-                let location = &stmt.location;
-                match &stmt.value {
-                    Return(e) => {
-                        let typ = match e {
-                            Some(e) => type_env.type_of_expr(e)?,
-                            None => type_env.lookup_type(location, "void")?.typ,
-                        };
-                        if i != b.statements.len() - 1 {
-                            return Err(SemanticError::dead_code_after_return_statement(location));
-                        }
-                        type_env.unify(location, return_type, &typ)?;
+            match &stmt.value {
+                Return(e) => {
+                    let typ = match e {
+                        Some(e) => type_env.type_of_expr_in_context(e, return_type)?,
+                        None => type_env.lookup_type(location, "void")?.typ,
+                    };
+                    if i != b.statements.len() - 1 {
+                        return Err(SemanticError::dead_code_after_return_statement(location));
                     }
-                    Variable(v) => {
-                        let var_def_typ = type_env.from(&v.typ)?;
-                        let var_typ = type_env.type_of_expr(&v.expr)?;
-                        type_env.unify(location, &var_def_typ, &var_typ)?;
+                    type_env.unify(location, return_type, &typ)?;
+                }
+                Variable(v) => {
+                    let var_def_typ = type_env.from(&v.typ)?;
+                    let var_typ = type_env.type_of_expr_in_context(&v.expr, &var_def_typ)?;
+                    type_env.unify(location, &var_def_typ, &var_typ)?;
+                    if v.mutable {
+                        type_env.add_mutable_variable(location, &v.name, var_def_typ)?;
+                    } else {
                         type_env.add_variable(location, &v.name, var_def_typ)?;
-                        if i == b.statements.len() - 1 {
-                            type_env.unify(
+                    }
+                }
+                Assign(name, expr) => {
+                    let var = type_env.lookup_variable(location, name)?;
+                    if !var.mutable {
+                        return Err(SemanticError::assign_to_immutable(
+                            location,
+                            name,
+                            &var.location,
+                        ));
+                    }
+                    let expr_typ = type_env.type_of_expr_in_context(expr, &var.typ)?;
+                    type_env.unify(&expr.location, &var.typ, &expr_typ)?;
+                }
+                Destructure(names, typ, expr) => {
+                    let def_typ = type_env.from(typ)?;
+                    let element_types = match &def_typ {
+                        Type::TupleType(types) if types.len() == names.len() => types.clone(),
+                        _ => {
+                            return Err(SemanticError::cannot_destructure(
                                 location,
-                                return_type,
-                                &type_env.lookup_type(location, "void")?.typ,
-                            )?;
+                                def_typ,
+                                names.len(),
+                            ))
                         }
+                    };
+                    let expr_typ = type_env.type_of_expr(expr)?;
+                    type_env.unify(&expr.location, &def_typ, &expr_typ)?;
+                    for (name, typ) in names.iter().zip(element_types) {
+                        type_env.add_variable(location, name, typ)?;
                     }
-                    Expression(func_call) => {
-                        let func_call_ret_typ = type_env.type_of_expr(func_call)?;
+                }
+                Expression(func_call) => {
+                    let func_call_ret_typ = type_env.type_of_expr(func_call)?;
+                    type_env.unify(
+                        location,
+                        &type_env.lookup_type(location, "void")?.typ,
+                        &func_call_ret_typ,
+                    )?;
+                }
+                IfBlock {
+                    clauses,
+                    else_block,
+                } => {
+                    for (condition, then_block) in clauses {
+                        let cond_type = type_env.type_of_expr(condition)?;
                         type_env.unify(
                             location,
-                            &type_env.lookup_type(location, "void")?.typ,
-                            &func_call_ret_typ,
+                            &type_env.lookup_type(location, "bool")?.typ,
+                            &cond_type,
                         )?;
-                        if i == b.statements.len() - 1 {
-                            type_env.unify(
-                                location,
-                                return_type,
-                                &type_env.lookup_type(location, "void")?.typ,
-                            )?;
-                        }
+                        self.check_block(&type_env, then_block, return_type, in_loop)?;
                     }
-                    IfBlock {
-                        clauses,
-                        else_block,
-                    } => {
-                        for (condition, then_block) in clauses {
-                            let cond_type = type_env.type_of_expr(condition)?;
-                            type_env.unify(
-                                location,
-                                &type_env.lookup_type(location, "bool")?.typ,
-                                &cond_type,
-                            )?;
-                            self.check_block(&type_env, then_block, return_type)?;
-                        }
-                        self.check_block(&type_env, else_block, return_type)?;
+                    self.check_block(&type_env, else_block, return_type, in_loop)?;
+                }
+                Loop(block) => {
+                    self.check_block(&type_env, block, return_type, true)?;
+                }
+                While(condition, block) => {
+                    let cond_type = type_env.type_of_expr(condition)?;
+                    type_env.unify(
+                        location,
+                        &type_env.lookup_type(location, "bool")?.typ,
+                        &cond_type,
+                    )?;
+                    self.check_block(&type_env, block, return_type, true)?;
+                }
+                Break => {
+                    if !in_loop {
+                        return Err(SemanticError::outside_of_loop(location, "break"));
+                    }
+                }
+                Continue => {
+                    if !in_loop {
+                        return Err(SemanticError::outside_of_loop(location, "continue"));
                     }
                 }
             }
@@ -309,6 +417,40 @@ impl SemanticChecker {
     }
 }
 
+/// falls_through is true if the execution can reach the end of block, e.g. a block without
+/// `return`, or an `if` without `else`
+fn falls_through(b: &Block) -> bool {
+    b.statements.last().map_or(true, statement_falls_through)
+}
+
+/// statement_falls_through is true if the execution can reach the next statement
+fn statement_falls_through(stmt: &Statement) -> bool {
+    match &stmt.value {
+        StatementVariant::Return(..) | StatementVariant::Break | StatementVariant::Continue => {
+            false
+        }
+        StatementVariant::IfBlock {
+            clauses,
+            else_block,
+        } => clauses.iter().any(|(_, b)| falls_through(b)) || falls_through(else_block),
+        // a loop never ends without `break`
+        StatementVariant::Loop(block) => has_break(block),
+        _ => true,
+    }
+}
+
+/// has_break is true if the block contains a `break` to leave the loop it belongs to
+fn has_break(b: &Block) -> bool {
+    b.statements.iter().any(|stmt| match &stmt.value {
+        StatementVariant::Break => true,
+        StatementVariant::IfBlock {
+            clauses,
+            else_block,
+        } => clauses.iter().any(|(_, b)| has_break(b)) || has_break(else_block),
+        _ => false,
+    })
+}
+
 fn with_module_name(mut module_name: String, name: &String) -> String {
     module_name.push('.');
     module_name.push_str(name);
@@ -0,0 +1,190 @@
+use crate::ast::*;
+use crate::lexer::Location;
+
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum NamingConvention {
+    /// `snake_case`
+    SnakeCase,
+    /// `PascalCase`
+    PascalCase,
+}
+
+impl NamingConvention {
+    /// convert returns name in this convention
+    pub fn convert(&self, name: &str) -> String {
+        match self {
+            NamingConvention::SnakeCase => to_snake_case(name),
+            NamingConvention::PascalCase => to_pascal_case(name),
+        }
+    }
+}
+
+impl std::fmt::Display for NamingConvention {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        match self {
+            NamingConvention::SnakeCase => write!(f, "snake_case"),
+            NamingConvention::PascalCase => write!(f, "PascalCase"),
+        }
+    }
+}
+
+/// NamingPolicy picks the convention of values(function, variable, field, parameter) and types(class,
+/// trait), `None` means no convention
+pub struct NamingPolicy {
+    pub value: Option<NamingConvention>,
+    pub typ: Option<NamingConvention>,
+}
+
+impl NamingPolicy {
+    pub fn new() -> NamingPolicy {
+        NamingPolicy {
+            value: Some(NamingConvention::SnakeCase),
+            typ: Some(NamingConvention::PascalCase),
+        }
+    }
+}
+
+#[derive(Debug, PartialEq)]
+pub struct NamingWarning {
+    pub location: Location,
+    pub name: String,
+    pub convention: NamingConvention,
+    pub suggestion: String,
+}
+
+impl std::fmt::Display for NamingWarning {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        write!(
+            f,
+            "`{}` should be {}, e.g. `{}`",
+            self.name, self.convention, self.suggestion
+        )
+    }
+}
+
+/// check_naming is a lint, it only reports names not following the policy and has no effect on
+/// compiling
+pub fn check_naming(module: &Module, policy: &NamingPolicy) -> Vec<NamingWarning> {
+    let mut lint = NamingLint {
+        policy,
+        warnings: vec![],
+    };
+    for top in &module.top_list {
+        match top {
+            TopAst::Import(_) => (),
+            TopAst::Function(f) => lint.function(f),
+            TopAst::Variable(v) => lint.value(&v.location, &v.name),
+            TopAst::Class(c) => {
+                lint.typ(&c.location, &c.name);
+                for member in &c.members {
+                    match member {
+                        ClassMember::Field(f) => lint.value(&f.location, &f.name),
+                        ClassMember::Method(f) | ClassMember::StaticMethod(f) => lint.function(f),
+                    }
+                }
+            }
+            TopAst::Trait(t) => {
+                lint.typ(&t.location, &t.name);
+                for member in &t.members {
+                    match member {
+                        TraitMember::Field(f) => lint.value(&f.location, &f.name),
+                        TraitMember::Method(f) => lint.function(f),
+                    }
+                }
+            }
+        }
+    }
+    lint.warnings
+}
+
+struct NamingLint<'a> {
+    policy: &'a NamingPolicy,
+    warnings: Vec<NamingWarning>,
+}
+
+impl<'a> NamingLint<'a> {
+    fn function(&mut self, f: &Function) {
+        self.value(&f.location, &f.name);
+        for p in &f.parameters {
+            // `self` is inserted by trait
+            if p.name != "self" {
+                self.value(&p.location, &p.name);
+            }
+        }
+        if let Some(Body::Block(b)) = &f.body {
+            self.block(b);
+        }
+    }
+    fn block(&mut self, b: &Block) {
+        for stmt in &b.statements {
+            match &stmt.value {
+                StatementVariant::Variable(v) => self.value(&v.location, &v.name),
+                StatementVariant::Destructure(names, ..) => {
+                    for name in names {
+                        self.value(&stmt.location, name);
+                    }
+                }
+                StatementVariant::IfBlock {
+                    clauses,
+                    else_block,
+                } => {
+                    for (_, then_block) in clauses {
+                        self.block(then_block);
+                    }
+                    self.block(else_block);
+                }
+                StatementVariant::Loop(body) | StatementVariant::While(_, body) => self.block(body),
+                _ => (),
+            }
+        }
+    }
+    fn value(&mut self, location: &Location, name: &String) {
+        self.check(location, name, self.policy.value);
+    }
+    fn typ(&mut self, location: &Location, name: &String) {
+        self.check(location, name, self.policy.typ);
+    }
+    fn check(&mut self, location: &Location, name: &String, convention: Option<NamingConvention>) {
+        if let Some(convention) = convention {
+            let suggestion = convention.convert(name);
+            if &suggestion != name {
+                self.warnings.push(NamingWarning {
+                    location: location.clone(),
+                    name: name.clone(),
+                    convention,
+                    suggestion,
+                });
+            }
+        }
+    }
+}
+
+/// to_snake_case keeps leading underscores, e.g. `_Foo` to `_foo`, `HTTPServer` to `http_server`
+fn to_snake_case(name: &str) -> String {
+    let cs: Vec<char> = name.chars().collect();
+    let mut s = String::new();
+    for (i, c) in cs.iter().enumerate() {
+        if c.is_uppercase() && i > 0 && cs[i - 1] != '_' {
+            let prev_is_lower = cs[i - 1].is_lowercase() || cs[i - 1].is_numeric();
+            let next_is_lower = cs.get(i + 1).map_or(false, |n| n.is_lowercase());
+            if prev_is_lower || (cs[i - 1].is_uppercase() && next_is_lower) {
+                s.push('_');
+            }
+        }
+        s.extend(c.to_lowercase());
+    }
+    s
+}
+
+/// to_pascal_case drops underscores, e.g. `my_type` to `MyType`
+fn to_pascal_case(name: &str) -> String {
+    let mut s = String::new();
+    for word in name.split('_') {
+        let mut cs = word.chars();
+        if let Some(c) = cs.next() {
+            s.extend(c.to_uppercase());
+            s.extend(cs);
+        }
+    }
+    s
+}
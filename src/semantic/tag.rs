@@ -2,6 +2,7 @@ use crate::ast::Tag;
 
 pub(crate) trait SemanticTag {
     fn is_extern(&self) -> bool;
+    fn is_builtin(&self) -> bool;
 }
 
 impl SemanticTag for Option<Tag> {
@@ -15,4 +16,11 @@ impl SemanticTag for Option<Tag> {
             None => false,
         }
     }
+    /// is_builtin is a function implemented by compiler, e.g. `print`
+    fn is_builtin(&self) -> bool {
+        match self {
+            Some(tag) => tag.name.as_str() == "builtin",
+            None => false,
+        }
+    }
 }
@@ -12,6 +12,79 @@ fn test_redefine_variable_would_get_error() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn inner_block_can_shadow_outer_variable() -> Result<()> {
+    let code = "
+    shadow(x: int): bool {
+      y: int = x + 1;
+      while y > 0 {
+        y: bool = true;
+        return y;
+      }
+      return y > x;
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn redefine_local_variable_in_the_same_block() {
+    let code = "
+    foo(): void {
+      y: int = 1;
+      y: int = 2;
+    }
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(err.message().contains("name: `y` be redefined"), true);
+}
+
+#[test]
+fn assign_mutable_variable() -> Result<()> {
+    let code = "
+    count(n: int): void {
+      mut i: int = 0;
+      while i < n {
+        println(\"tick\");
+        i = i + 1;
+      }
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn assign_in_loop_before_return() -> Result<()> {
+    let code = "
+    sum(n: int): int {
+      mut i: int = 0;
+      mut total: int = 0;
+      while i < n {
+        i = i + 1;
+        total = total + i;
+      }
+      return total;
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn assign_immutable_variable() {
+    let code = "
+    foo(): void {
+      x: int = 1;
+      x = 2;
+    }
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .contains("cannot assign to immutable variable: `x`"),
+        true
+    );
+}
+
 #[test]
 fn test_function_and_variable_use_the_same_space() {
     let code = "
@@ -22,12 +95,330 @@ fn test_function_and_variable_use_the_same_space() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn redefined_function_reports_both_positions() {
+    let code = "foo(): void {}\nfoo(): int = 1;";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(err.location(), Location::from(2, 0));
+    assert_eq!(err.message().ends_with("already defined at :1:0"), true);
+}
+
+#[test]
+fn distinct_function_names() -> Result<()> {
+    let code = "foo(): void {}\nbar(): int = 1;";
+    check_code(code)
+}
+
 #[test]
 fn binary_expression() -> Result<()> {
     let code = "add(x: int, y: int): int = x + y;";
     check_code(code)
 }
 
+#[test]
+fn unary_plus_on_number() -> Result<()> {
+    let code = "
+    x: int = +1;
+    add(a: int, b: int): int = a + +b;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn unary_plus_on_non_number() {
+    let code = "x: bool = +true;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn unary_minus_on_number() -> Result<()> {
+    let code = "
+    x: int = -1;
+    neg(a: f64): f64 = -a;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn unary_minus_on_non_number() {
+    let code = "x: string = -\"a\";";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn unary_not_on_bool() -> Result<()> {
+    let code = "
+    x: bool = !true;
+    nand(a: bool, b: bool): bool = !(a && b);
+    ";
+    check_code(code)
+}
+
+#[test]
+fn unary_not_on_non_bool() {
+    let code = "x: int = !1;";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot apply unary operator `!` on type: `int`"),
+        true
+    );
+}
+
+#[test]
+fn cast_between_numbers() -> Result<()> {
+    let code = "
+    widen(x: i32): int = x as int;
+    to_f32(x: int): f32 = x as f32;
+    flag(b: bool): int = b as int;
+    ratio(a: int, b: int): f64 = a as f64 / b as f64;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn binary_operators_on_sized_numbers() -> Result<()> {
+    let code = "
+    add(a: i32, b: i32): i32 = a + b;
+    lt(a: f32, b: f32): bool = a < b;
+    eq(a: f32, b: f32): bool = a == b;
+    small(x: i8): bool = x * 2'i8 != 0'i8;
+    mid(x: i16): i16 = x % 10'i16;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn pow_on_sized_numbers_is_rejected() {
+    let err = check_code("p(a: i32): i32 = a ^ a;").unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot apply binary operator `^` on type: `i32` and `i32`"),
+        true
+    );
+}
+
+#[test]
+fn cast_string_to_int() {
+    let code = "x: int = \"a\" as int;";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot cast a value of type: `string` to `int`"),
+        true
+    );
+}
+
+#[test]
+fn match_on_integers() -> Result<()> {
+    let code = "
+    classify(x: i32): int = match x { 1 => 10, -2 => 20, _ => 0 };
+    vowel(c: char): bool = match c { 'a' => true, 'e' => true, _ => false };
+    ";
+    check_code(code)
+}
+
+#[test]
+fn match_without_default_arm() {
+    let code = "classify(x: int): int = match x { 1 => 10, 2 => 20 };";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("match on `int` must have a default arm `_`"),
+        true
+    );
+}
+
+#[test]
+fn match_duplicate_pattern() {
+    let code = "classify(x: int): int = match x { 1 => 10, 1 => 20, _ => 0 };";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("unreachable match arm, the pattern is already matched"),
+        true
+    );
+}
+
+#[test]
+fn integer_literal_can_be_f64_by_context() -> Result<()> {
+    let code = "
+    x: f64 = 3;
+    y: f64 = 3.5;
+    w: f64 = -1;
+    v: f64 = 1 + 1;
+    foo(): f64 = 1;
+    bar(): f64 {
+      z: f64 = 2;
+      return -(2 * 3);
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn integer_expression_is_not_f64_by_context() {
+    let code = "
+    foo(a: int): f64 = a + 1;
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("type mismatched, expected: `f64` but got: `int`"),
+        true
+    );
+}
+
+#[test]
+fn suffixed_integer_is_not_f64_by_context() {
+    let code = "
+    x: i8 = -5'i8;
+    y: f64 = 1'i32;
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("type mismatched, expected: `f64` but got: `i32`"),
+        true
+    );
+}
+
+#[test]
+fn float_literal_cannot_be_int() {
+    let code = "x: int = 3.2;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn not_equal_on_same_type() -> Result<()> {
+    let code = "
+    ne_int(a: int, b: int): bool = a != b;
+    ne_f64(a: f64, b: f64): bool = a != b;
+    ne_bool(a: bool, b: bool): bool = a != b;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn not_equal_on_different_type() {
+    let code = "ne(a: int, b: bool): bool = a != b;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn char_literal() -> Result<()> {
+    let code = "
+    c: char = 'a';
+    newline(): char = '\\n';
+    ";
+    check_code(code)
+}
+
+#[test]
+fn char_is_not_int() {
+    let code = "c: int = 'a';";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn arithmetic_and_comparison() -> Result<()> {
+    let code = "
+    f(a: f64, b: f64): f64 = a * b - a / b + a;
+    lt(a: int, b: int): bool = a - 1 < b * 2;
+    ge(a: f64, b: f64): bool = a >= b;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn equality_on_string() -> Result<()> {
+    let code = "
+    eq(a: string, b: string): bool = a == b;
+    ne(a: string, b: string): bool = a != b;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn comparison_on_bool_is_invalid() {
+    let code = "lt(a: bool, b: bool): bool = a < b;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn logical_operator_on_bool() -> Result<()> {
+    let code = "
+    between(a: int, lo: int, hi: int): bool = lo <= a && a <= hi || a == 0;
+    ";
+    check_code(code)?;
+    let code = "both(a: int, b: int): bool = a && b;";
+    assert_eq!(check_code(code).is_err(), true);
+    Ok(())
+}
+
+#[test]
+fn equal_on_strings() -> Result<()> {
+    let code = "
+    eq(a: string, b: string): bool = a == b;
+    eq_int(a: int, b: int): bool = a == b;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn string_cannot_equal_to_non_string() {
+    let code = "eq(a: string, b: int): bool = a == b;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn distinct_parameter_names() -> Result<()> {
+    let code = "foo(a: int, b: int): int = a;";
+    check_code(code)
+}
+
+#[test]
+fn duplicate_parameter_names() {
+    let code = "foo(a: int, a: f64): int = a;";
+    let result = check_code(code);
+    // report at the second `a`
+    assert_eq!(result.unwrap_err().location(), Location::from(1, 12));
+}
+
+#[test]
+fn pow_on_number() -> Result<()> {
+    let code = "
+    x: int = 2 ^ 3;
+    y: f64 = 2.0 ^ 0.5;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn pow_on_mixed_number() {
+    let code = "x: int = 2 ^ 0.5;";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn compare_int_with_f64() {
+    let code = "lt(a: int, b: f64): bool = a < b;";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot apply binary operator `<` on type: `int` and `f64`"),
+        true
+    );
+}
+
 #[test]
 fn heterogeneous_list() {
     let code = "x: List[int] = [1, \"s\"];";
@@ -83,23 +474,110 @@ fn call_void_function_as_statement() -> Result<()> {
 fn test_check_function_call() -> Result<()> {
     let code = "
     x(a: int): int = a;
-    y: int = x(2);
+    main(): void {
+      y: int = x(2);
+    }
     ";
     check_code(code)
 }
 
+#[test]
+fn call_with_wrong_argument_count() {
+    let code = "
+    add(a: int, b: int): int = a + b;
+    x: int = add(1);
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message(),
+        ":3:13 call to `add`: expected 2 arguments but got 1"
+    );
+    let code = "
+    add(a: int, b: int): int = a + b;
+    x: int = add(1, \"2\");
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+}
+
 #[test]
 fn test_unify_list_type() -> Result<()> {
     let code = "
-    x: List[int] = [1, 2, 3];
+    main(): void {
+      x: List[int] = [1, 2, 3];
+    }
     ";
     check_code(code)
 }
 
+#[test]
+fn return_and_destructure_tuple() -> Result<()> {
+    let code = "
+    div_mod(a: int, b: int): (int, int) = (a / b, a % b);
+    sum(): int {
+      (q, r): (int, int) = div_mod(7, 2);
+      return q + r;
+    }
+    ";
+    check_code(code)?;
+    let code = "
+    pair(): (int, int) = (1, 2.0);
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    let code = "
+    first(): int {
+      (a, b, c): (int, int) = (1, 2);
+      return a;
+    }
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot destructure a value of type: `(int, int)` into 3 names"),
+        true
+    );
+    Ok(())
+}
+
+#[test]
+fn index_list() -> Result<()> {
+    let code = "
+    get(xs: List[f64], i: int): f64 = xs[i];
+    ";
+    check_code(code)?;
+    let code = "
+    get(xs: List[f64]): int = xs[0];
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    let code = "
+    get(xs: List[f64]): f64 = xs[1.0];
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    let code = "
+    get(x: int): int = x[0];
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("cannot index into a value of type: `int`"),
+        true
+    );
+    Ok(())
+}
+
+#[test]
+fn test_unify_list_element_type() {
+    let code = "
+    x: List[f64] = [true];
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+}
+
 #[test]
 fn test_unify_free_var() -> Result<()> {
     let code = "
-    x: List[int] = [];
+    main(): void {
+      x: List[int] = [];
+    }
     ";
     check_code(code)
 }
@@ -178,6 +656,53 @@ fn test_cannot_use_class_construction_out_of_class() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn global_initializer_must_be_constant() {
+    let codes = [
+        "y: int = 1;\nx: int = y + 1;",
+        "x: int = 1 / 0;",
+        "x: int = 2 ^ -1;",
+        "y: int = 1;\nx: List[int] = [y];",
+        "y: int = 1;\nx: List[int] = [y; 4];",
+    ];
+    for code in codes.iter() {
+        let err = check_code(code).unwrap_err();
+        assert_eq!(
+            err.message()
+                .ends_with("global initializer must be a constant"),
+            true
+        );
+    }
+}
+
+#[test]
+fn global_initializer_can_be_a_constant_cast() -> Result<()> {
+    let code = "
+    x: f64 = 1 as f64;
+    y: f32 = 1.5'f32;
+    z: i16 = 70000 as i16;
+    w: i32 = 3'i32 + 4'i32;
+    c: char = 65 as char;
+    ";
+    check_code(code)
+}
+
+#[test]
+fn global_initializer_can_be_a_constant_list() -> Result<()> {
+    check_code("x: List[int] = [];\ny: List[int] = [0; 4];\nz: List[f64] = [0.5, 2.5];")
+}
+
+#[test]
+fn global_list_repeats_non_zero_element_at_most_the_limit() {
+    assert_eq!(check_code("x: List[int] = [0; 1000000];").is_ok(), true);
+    assert_eq!(check_code("x: List[int] = [1; 65536];").is_ok(), true);
+    let err = check_code("x: List[int] = [1; 65537];").unwrap_err();
+    assert_eq!(
+        err.message(),
+        ":1:15 global list repeats a non-zero element 65537 times, at most 65536 is allowed"
+    );
+}
+
 #[test]
 fn test_global_should_be_able_to_use_class_static_method() -> Result<()> {
     let code = "
@@ -235,6 +760,47 @@ fn method_should_be_able_to_call_with_instance() -> Result<()> {
     check_code(code)
 }
 
+#[test]
+fn call_undefined_method() {
+    let code = "
+    class Foo {
+      bar(): void {}
+    }
+    baz(f: Foo): void = f.baz();
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.to_string()
+            .ends_with("class `Foo` has no member named `baz`"),
+        true
+    );
+}
+
+#[test]
+fn method_can_access_field_with_or_without_self() -> Result<()> {
+    let code = "
+    class Foo {
+      x: int;
+      get_x(): int = x;
+      get_self_x(): int = self.x;
+      shadow(x: bool): bool = x;
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn static_method_has_no_self() {
+    let code = "
+    class Foo {
+      x: int;
+      ::get_x(): int = self.x;
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
 #[test]
 fn non_extern_function_cannot_without_body() {
     let code = "
@@ -254,6 +820,74 @@ fn only_trait_can_be_super_type() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn class_implements_trait() -> Result<()> {
+    let code = "
+    trait Show {
+      show(x: int): int;
+    }
+    class Foo <: Show {
+      show(x: int): int = x;
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn class_can_be_used_as_trait() -> Result<()> {
+    let code = "
+    trait Show {
+      show(x: int): int;
+    }
+    class Foo <: Show {
+      show(x: int): int = x;
+    }
+    show(s: Show): int = s.show(1);
+    foo(f: Foo): int = show(f);
+    ";
+    check_code(code)?;
+    let code = "
+    trait Show {
+      show(x: int): int;
+    }
+    class Bar {}
+    show(s: Show): int = s.show(1);
+    bar(b: Bar): int = show(b);
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    Ok(())
+}
+
+#[test]
+fn trait_method_signature_mismatched() {
+    let code = "
+    trait Show {
+      show(x: int): int;
+    }
+    class Foo <: Show {
+      show(x: int): f64 = 1.0;
+    }
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("method `show`: expected (int): int but got (int): f64"),
+        true
+    );
+}
+
+#[test]
+fn class_missing_trait_method() {
+    let code = "
+    trait Show {
+      show(x: int): int;
+    }
+    class Foo <: Show {}
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
 #[test]
 fn if_else_block_must_return_same_type_as_return_type() {
     let code = "
@@ -280,6 +914,14 @@ fn condition_must_be_a_bool() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn final_expression_must_match_return_type() {
+    let result = check_code("foo(): int { 1 }");
+    assert_eq!(result.is_ok(), true);
+    let result = check_code("foo(): int { true }");
+    assert_eq!(result.is_err(), true);
+}
+
 #[test]
 fn missing_return_statement_is_invalid() {
     let code = "
@@ -294,6 +936,51 @@ fn missing_return_statement_is_invalid() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn if_without_else_before_return() -> Result<()> {
+    let code = "
+    foo(x: int): int {
+      if x > 0 {
+        println(\"positive\");
+      }
+      return x;
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn recursive_function() -> Result<()> {
+    let code = "
+    factorial(n: int): int {
+      if n <= 1 {
+        return 1;
+      }
+      return n * factorial(n - 1);
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn missing_return_on_some_path() {
+    let code = "
+    sign(x: int): int {
+      if x > 0 {
+        return 1;
+      } else if x < 0 {
+        return -1;
+      }
+    }
+    ";
+    let err = check_code(code).unwrap_err();
+    assert_eq!(
+        err.message()
+            .ends_with("function `sign` must return a value of type: `int` on every path"),
+        true
+    );
+}
+
 #[test]
 fn dead_code_after_return_statement_is_invalid() {
     let code = "
@@ -306,6 +993,124 @@ fn dead_code_after_return_statement_is_invalid() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn statement_after_if_else_that_always_returns_is_unreachable() {
+    let codes = [
+        "
+    sign(x: int): int {
+      if x < 0 {
+        return -1;
+      } else {
+        return 1;
+      }
+      println(\"never\");
+    }
+    ",
+        "
+    foo(): void {
+      loop {
+        break;
+        println(\"never\");
+      }
+    }
+    ",
+    ];
+    for code in codes.iter() {
+        let err = check_code(code).unwrap_err();
+        assert_eq!(
+            err.message()
+                .ends_with("unreachable statement, the execution never reaches here"),
+            true
+        );
+    }
+}
+
+#[test]
+fn break_in_loop() -> Result<()> {
+    let code = "
+    done(): bool = true;
+    foo(): void {
+      loop {
+        if done() {
+          break;
+        }
+      }
+    }
+    ";
+    check_code(code)
+}
+
+#[test]
+fn break_outside_of_loop_is_invalid() {
+    let code = "
+    foo(): void {
+      if true {
+        break;
+      }
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn continue_outside_of_loop_is_invalid() {
+    let code = "
+    foo(): void {
+      continue;
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+    let code = "
+    skip(): bool = true;
+    foo(): void {
+      loop {
+        if skip() {
+          continue;
+        }
+        break;
+      }
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_ok(), true);
+}
+
+#[test]
+fn while_condition_must_be_a_bool() {
+    let code = "
+    foo(n: int): int {
+      while n > 0 {
+        break;
+      }
+      return n;
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_ok(), true);
+    let code = "
+    foo(): void {
+      while 1 {}
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
+#[test]
+fn loop_with_break_must_not_end_non_void_function() {
+    let code = "
+    foo(): int {
+      loop {
+        break;
+      }
+    }
+    ";
+    let result = check_code(code);
+    assert_eq!(result.is_err(), true);
+}
+
 #[test]
 fn init_expression_must_has_same_type_as_define() {
     let code = "
@@ -317,6 +1122,33 @@ fn init_expression_must_has_same_type_as_define() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn class_construction_checks_fields() -> Result<()> {
+    let code = "
+    class Point {
+      x: int;
+      y: f64;
+      ::new(x: int): Point = Point {x: x, y: 2};
+    }
+    ";
+    check_code(code)?;
+    let code = "
+    class Point {
+      x: int;
+      ::new(): Point = Point {x: 1, z: 2};
+    }
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    let code = "
+    class Point {
+      x: int;
+      ::new(): Point = Point {x: \"1\"};
+    }
+    ";
+    assert_eq!(check_code(code).is_err(), true);
+    Ok(())
+}
+
 #[test]
 fn redefine_field_is_invalid() {
     let code = "
@@ -351,7 +1183,104 @@ fn no_member_name() {
     assert_eq!(result.is_err(), true);
 }
 
+#[test]
+fn naming_lint_on_function_under_snake_case() {
+    let module = parse_module("MyFunc(): void {}");
+    let policy = naming::NamingPolicy {
+        value: Some(naming::NamingConvention::SnakeCase),
+        typ: None,
+    };
+    let warnings = naming::check_naming(&module, &policy);
+    assert_eq!(warnings.len(), 1);
+    assert_eq!(
+        format!("{}", warnings[0]),
+        "`MyFunc` should be snake_case, e.g. `my_func`"
+    );
+}
+
+#[test]
+fn naming_lint_on_class_under_pascal_case() {
+    let module = parse_module("class my_type { http_server: int; }");
+    let warnings = naming::check_naming(&module, &naming::NamingPolicy::new());
+    assert_eq!(warnings.len(), 1);
+    assert_eq!(warnings[0].suggestion, "MyType");
+
+    let policy = naming::NamingPolicy {
+        value: None,
+        typ: None,
+    };
+    assert_eq!(naming::check_naming(&module, &policy), vec![]);
+}
+
+#[test]
+fn constant_condition_lint() {
+    let module = parse_module(
+        "
+    foo(a: int): void {
+      if 1 == 1 {} else if a != 2 {} else if false {}
+      loop { x: int = match a { 1 => match 2 { _ => 0 }, _ => 1 }; }
+    }
+    bar(): int = match 1 + 1 { 2 => 1, _ => 0 };
+    ",
+    );
+    let warnings = condition::check_conditions(&module);
+    let warnings: Vec<_> = warnings
+        .iter()
+        .map(|w| (format!("{}", w.location), format!("{}", w)))
+        .collect();
+    assert_eq!(
+        warnings,
+        vec![
+            (
+                ":3:9".to_string(),
+                "condition is always true, the branch is always taken".to_string()
+            ),
+            (
+                ":3:45".to_string(),
+                "condition is always false, the branch is never taken".to_string()
+            ),
+            (
+                ":4:43".to_string(),
+                "matched value is a constant, only one arm is taken".to_string()
+            ),
+            (
+                ":6:23".to_string(),
+                "matched value is a constant, only one arm is taken".to_string()
+            ),
+        ]
+    );
+}
+
+#[test]
+fn constants_of_different_kinds_are_not_ordered() {
+    let evaluate =
+        |code| constant::evaluate(&Parser::new("", code).parse_expression(None, None).unwrap());
+    assert_eq!(evaluate("1 < 'a'"), None);
+    assert_eq!(evaluate("1 == 1.0"), None);
+    assert_eq!(evaluate("'a' < 'b'"), Some(constant::Constant::Bool(true)));
+}
+
+#[test]
+fn constant_pow_takes_the_whole_exponent() {
+    let evaluate =
+        |code| constant::evaluate(&Parser::new("", code).parse_expression(None, None).unwrap());
+    // `2 ^ 4294967296` wraps to `0`, not `2 ^ 0`
+    assert_eq!(evaluate("2 ^ 4294967296"), Some(constant::Constant::Int(0)));
+    assert_eq!(
+        evaluate("-1 ^ 4294967297"),
+        Some(constant::Constant::Int(-1))
+    );
+    assert_eq!(evaluate("3 ^ 4"), Some(constant::Constant::Int(81)));
+}
+
 // helpers, must put tests before this line
+fn parse_module(code: &'static str) -> Module {
+    let mut parser = Parser::new("", code);
+    Module {
+        name: "test".to_string(),
+        top_list: parser.parse_top_list(TkType::EOF).unwrap(),
+    }
+}
 fn check_code(code: &'static str) -> Result<()> {
     let mut parser = Parser::new("", code);
     let mut code = parser
@@ -369,10 +1298,16 @@ fn check_code(code: &'static str) -> Result<()> {
             "int".to_string(),
             "void".to_string(),
             "f64".to_string(),
+            "i8".to_string(),
+            "i16".to_string(),
+            "i32".to_string(),
+            "f32".to_string(),
             "bool".to_string(),
+            "char".to_string(),
             "string".to_string(),
             "List".to_string(),
             "println".to_string(),
+            "print".to_string(),
         ],
     }));
 
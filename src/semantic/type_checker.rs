@@ -3,6 +3,7 @@ use super::error::SemanticError;
 use crate::ast;
 use crate::ast::*;
 use crate::ast::{Function, ParsedType};
+use crate::constant::{evaluate, Constant};
 use crate::lexer::Location;
 use std::collections::HashMap;
 
@@ -18,6 +19,20 @@ pub struct TypeEnv {
 }
 
 impl TypeEnv {
+    /// type_of_expr_in_context is type_of_expr with an expected type from context, an `int`
+    /// constant would be a `f64` when `f64` is expected, e.g. `x: f64 = 1;` or `x: f64 = -1;`, but
+    /// `x: f64 = 1'i32;` is still an error
+    pub(crate) fn type_of_expr_in_context(&mut self, expr: &Expr, expected: &Type) -> Result<Type> {
+        let typ = self.type_of_expr(expr)?;
+        match (&typ, expected, evaluate(expr)) {
+            (
+                Type::ClassType { name: from, .. },
+                Type::ClassType { name: to, .. },
+                Some(Constant::Int(_)),
+            ) if from == "int" && to == "f64" => Ok(expected.clone()),
+            _ => Ok(typ),
+        }
+    }
     pub(crate) fn type_of_expr(&mut self, expr: &Expr) -> Result<Type> {
         use ExprVariant::*;
         let location = &expr.location;
@@ -25,25 +40,54 @@ impl TypeEnv {
             Binary(l, r, op) => {
                 let left_type = self.type_of_expr(l)?;
                 let right_type = self.type_of_expr(r)?;
-                match (left_type, right_type, op) {
-                    (
-                        Type::ClassType { name: n1, .. },
-                        Type::ClassType { name: n2, .. },
-                        Operator::Plus,
-                    ) => {
-                        if n1.as_str() == "int" && n1 == n2 {
-                            Ok(self.lookup_type(location, "int")?.typ)
+                let operand_types: &[&str] = match op {
+                    Operator::Equal | Operator::NotEqual => &[
+                        "int", "i32", "i16", "i8", "f64", "f32", "bool", "char", "string",
+                    ],
+                    Operator::And | Operator::Or => &["bool"],
+                    // `^` calls `llvm.pow.f64` or `_pow_int` at runtime, both take 64 bits
+                    Operator::Pow => &["int", "f64"],
+                    _ => &["int", "i32", "i16", "i8", "f64", "f32"],
+                };
+                match (&left_type, &right_type) {
+                    (Type::ClassType { name: n1, .. }, Type::ClassType { name: n2, .. })
+                        if n1 == n2 && operand_types.contains(&n1.as_str()) =>
+                    {
+                        if op.is_comparison() {
+                            Ok(self.lookup_type(location, "bool")?.typ)
                         } else {
-                            panic!("sadasdasda")
+                            Ok(left_type.clone())
                         }
                     }
-                    (l, r, op) => panic!("unsupported operator, {} {:?} {}", l, op, r),
+                    (l, r) => Err(SemanticError::cannot_apply_binary_operator(
+                        location, op, l, r,
+                    )),
+                }
+            }
+            Unary(op, e) => {
+                let typ = self.type_of_expr(e)?;
+                match (op, &typ) {
+                    (UnaryOperator::Plus, Type::ClassType { name, .. })
+                    | (UnaryOperator::Minus, Type::ClassType { name, .. })
+                        if name.as_str() == "int" || name.as_str() == "f64" =>
+                    {
+                        Ok(typ)
+                    }
+                    (UnaryOperator::Not, Type::ClassType { name, .. })
+                        if name.as_str() == "bool" =>
+                    {
+                        Ok(typ)
+                    }
+                    (op, _) => Err(SemanticError::cannot_apply_unary_operator(
+                        location, op, &typ,
+                    )),
                 }
             }
             F64(_) => Ok(self.lookup_type(location, "f64")?.typ),
             Int(_) => Ok(self.lookup_type(location, "int")?.typ),
             Bool(_) => Ok(self.lookup_type(location, "bool")?.typ),
             String(_) => Ok(self.lookup_type(location, "string")?.typ),
+            Char(_) => Ok(self.lookup_type(location, "char")?.typ),
             List(es) => {
                 let expr_type: Type = if es.len() < 1 {
                     self.free_var()
@@ -59,12 +103,123 @@ impl TypeEnv {
                         ));
                     }
                 }
-                Ok(self.lookup_type(location, "List")?.typ)
+                Ok(self.list_type(location, expr_type)?)
+            }
+            ListRepeat(e, _) => {
+                let expr_type = self.type_of_expr(e)?;
+                Ok(self.list_type(location, expr_type)?)
+            }
+            Tuple(es) => {
+                let mut element_types = vec![];
+                for e in es {
+                    element_types.push(self.type_of_expr(e)?);
+                }
+                Ok(Type::TupleType(element_types))
+            }
+            Index(list, index) => {
+                let list_type = self.type_of_expr(list)?;
+                let element_type = match &list_type {
+                    Type::ClassType {
+                        name,
+                        type_parameters,
+                        ..
+                    } if name == "List" && type_parameters.len() == 1 => type_parameters[0].clone(),
+                    _ => return Err(SemanticError::cannot_index(&list.location, list_type)),
+                };
+                let index_type = self.type_of_expr(index)?;
+                self.unify(
+                    &index.location,
+                    &self.lookup_type(location, "int")?.typ,
+                    &index_type,
+                )?;
+                Ok(element_type)
+            }
+            Cast(e, typ) => {
+                let from = self.type_of_expr(e)?;
+                let to = self.from(typ)?;
+                // `bool` can be converted to a number, but not vice versa
+                let numbers = ["int", "i8", "i16", "i32", "char", "f64", "f32"];
+                match (&from, &to) {
+                    (Type::ClassType { name: n1, .. }, Type::ClassType { name: n2, .. })
+                        if (numbers.contains(&n1.as_str()) || n1 == "bool")
+                            && numbers.contains(&n2.as_str()) =>
+                    {
+                        Ok(to)
+                    }
+                    _ => Err(SemanticError::cannot_cast(location, from, to)),
+                }
+            }
+            Match(e, arms) => {
+                let typ = self.type_of_expr(e)?;
+                let name = match &typ {
+                    Type::ClassType { name, .. }
+                        if ["int", "i32", "char"].contains(&name.as_str()) =>
+                    {
+                        name.clone()
+                    }
+                    _ => return Err(SemanticError::cannot_match(location, typ)),
+                };
+                let mut matched = vec![];
+                let mut has_default = false;
+                let mut arm_type: Option<Type> = None;
+                for arm in arms {
+                    let arm_location = arm
+                        .pattern
+                        .as_ref()
+                        .map_or(&arm.expr.location, |p| &p.location);
+                    // nothing can reach an arm after `_`
+                    if has_default {
+                        return Err(SemanticError::unreachable_arm(arm_location));
+                    }
+                    match &arm.pattern {
+                        None => has_default = true,
+                        Some(pattern) => {
+                            let constant = match (evaluate(pattern), name.as_str()) {
+                                (Some(Constant::Int(i)), "int") => Constant::Int(i),
+                                (Some(Constant::Int(i)), "i32") if i as i32 as i64 == i => {
+                                    Constant::Int(i)
+                                }
+                                (Some(Constant::Char(c)), "char") => Constant::Char(c),
+                                _ => return Err(SemanticError::invalid_pattern(arm_location, typ)),
+                            };
+                            if matched.contains(&constant) {
+                                return Err(SemanticError::unreachable_arm(arm_location));
+                            }
+                            matched.push(constant);
+                        }
+                    }
+                    arm_type = Some(match arm_type {
+                        None => self.type_of_expr(&arm.expr)?,
+                        Some(expected) => {
+                            let t = self.type_of_expr_in_context(&arm.expr, &expected)?;
+                            self.unify(&arm.expr.location, &expected, &t)?;
+                            expected
+                        }
+                    });
+                }
+                // integers can't be listed, a default arm is always required
+                if !has_default {
+                    return Err(SemanticError::non_exhaustive_match(location, typ));
+                }
+                Ok(arm_type.unwrap())
             }
             FuncCall(f, args) => {
                 let f_type = self.type_of_expr(f)?;
                 match f_type {
                     Type::FunctionType(params, ret_typ) => {
+                        if params.len() != args.len() {
+                            let callee = match &f.value {
+                                Identifier(name) => name.clone(),
+                                MemberAccess(_, name) => name.clone(),
+                                _ => "function".to_string(),
+                            };
+                            return Err(SemanticError::argument_count_mismatched(
+                                location,
+                                callee,
+                                params.len(),
+                                args.len(),
+                            ));
+                        }
                         for (p, arg) in params.iter().zip(args.iter()) {
                             let typ = self.type_of_expr(&arg.expr)?;
                             self.unify(&arg.location, p, &typ)?;
@@ -80,7 +235,7 @@ impl TypeEnv {
             MemberAccess(from, access) => {
                 let typ = self.type_of_expr(from)?;
                 match typ {
-                    Type::ClassType { name, members, .. } => {
+                    Type::ClassType { name, members, .. } | Type::TraitType { name, members } => {
                         let member = members.get_member(location, name, access)?;
                         Ok(member.typ.clone())
                     }
@@ -100,9 +255,17 @@ impl TypeEnv {
                 let type_info = self.lookup_type(location, name)?;
                 match &type_info.typ {
                     Type::ClassType {
+                        name: class_name,
+                        members,
                         uninitialized_fields,
                         ..
                     } => {
+                        for (field_name, init) in field_inits {
+                            let member =
+                                members.get_member(location, class_name.clone(), field_name)?;
+                            let typ = self.type_of_expr_in_context(init, &member.typ)?;
+                            self.unify(&init.location, &member.typ, &typ)?;
+                        }
                         let should_inits = uninitialized_fields;
                         let mut missing_init_fields = vec![];
                         for should_init in should_inits {
@@ -157,6 +320,19 @@ impl TypeEnv {
                     Ok(())
                 }
             }
+            (TraitType { name, .. }, TraitType { name: name2, .. }) if name == name2 => Ok(()),
+            // a class can be used as trait it implements
+            (TraitType { .. }, ClassType { parents, .. }) => {
+                for parent in parents {
+                    if self.unify(location, expected, parent).is_ok() {
+                        return Ok(());
+                    }
+                }
+                Err(SemanticError::type_mismatched(location, expected, actual))
+            }
+            (TupleType(ts), TupleType(ts_p)) if ts.len() == ts_p.len() => {
+                self.unify_type_list(location, ts, ts_p)
+            }
             (FunctionType(ft, arg), FunctionType(ft_p, arg_p)) => {
                 self.unify_type_list(location, ft, ft_p)?;
                 self.unify(location, arg, arg_p)
@@ -216,9 +392,36 @@ impl TypeEnv {
         type_env
     }
     pub fn from(&self, typ: &ParsedType) -> Result<Type> {
-        Ok(self
+        if let ParsedType::Tuple(element_types) = typ {
+            let mut types = vec![];
+            for t in element_types {
+                types.push(self.from(t)?);
+            }
+            return Ok(Type::TupleType(types));
+        }
+        let mut result = self
             .lookup_type(&Location::none(), typ.name().as_str())?
-            .typ)
+            .typ;
+        // `List[int]` is `List` applied with `int`
+        if let Type::ClassType {
+            type_parameters, ..
+        } = &mut result
+        {
+            for generic in typ.generics() {
+                type_parameters.push(self.from(&generic)?);
+            }
+        }
+        Ok(result)
+    }
+    fn list_type(&self, location: &Location, element_type: Type) -> Result<Type> {
+        let mut typ = self.lookup_type(location, "List")?.typ;
+        if let Type::ClassType {
+            type_parameters, ..
+        } = &mut typ
+        {
+            type_parameters.push(element_type);
+        }
+        Ok(typ)
     }
     pub fn new_function_type(&self, f: &Function) -> Result<Type> {
         let mut param_types = vec![];
@@ -230,6 +433,45 @@ impl TypeEnv {
             self.from(&f.ret_typ)?.into(),
         ))
     }
+    pub fn new_trait(&mut self, t: &Trait) -> Result<Type> {
+        let mut members = ClassMembers::new();
+        for member in &t.members {
+            match member {
+                TraitMember::Field(field) => {
+                    members.add_member(
+                        t.name.clone(),
+                        ClassMember {
+                            name: field.name.clone(),
+                            location: field.location.clone(),
+                            typ: self.from(&field.typ)?,
+                        },
+                    )?;
+                }
+                TraitMember::Method(method) => {
+                    // skip `self`, it's inserted by parser and it's implementor in fact
+                    let mut param_types = vec![];
+                    for param in method.parameters.iter().skip(1) {
+                        param_types.push(self.from(&param.typ)?);
+                    }
+                    members.add_member(
+                        t.name.clone(),
+                        ClassMember {
+                            name: method.name.clone(),
+                            location: method.location.clone(),
+                            typ: Type::FunctionType(
+                                param_types,
+                                self.from(&method.ret_typ)?.into(),
+                            ),
+                        },
+                    )?;
+                }
+            }
+        }
+        Ok(Type::TraitType {
+            name: t.name.clone(),
+            members,
+        })
+    }
     pub fn new_class(&mut self, c: &Class) -> Result<Type> {
         let mut uninitialized_fields = vec![];
         let mut members = ClassMembers::new();
@@ -271,7 +513,30 @@ impl TypeEnv {
         for p_name in &c.parents {
             let parent_typ = self.lookup_type(&c.location, p_name.as_str())?;
             match &parent_typ.typ {
-                Type::TraitType => parents.push(parent_typ.typ),
+                Type::TraitType {
+                    members: trait_members,
+                    ..
+                } => {
+                    // class must provide every method of trait with the same signature
+                    let mut trait_members: Vec<_> = trait_members.0.iter().collect();
+                    // report in a stable order
+                    trait_members.sort_by_key(|(name, _)| name.to_string());
+                    for (name, trait_member) in trait_members {
+                        let member = members.get_member(&c.location, c.name.clone(), name)?;
+                        if self
+                            .unify(&member.location, &trait_member.typ, &member.typ)
+                            .is_err()
+                        {
+                            return Err(SemanticError::method_signature_mismatched(
+                                &member.location,
+                                name,
+                                &trait_member.typ,
+                                &member.typ,
+                            ));
+                        }
+                    }
+                    parents.push(parent_typ.typ)
+                }
                 t => return Err(SemanticError::only_trait_can_be_super_type(&c.location, t)),
             }
         }
@@ -287,14 +552,29 @@ impl TypeEnv {
 
 impl TypeEnv {
     pub(crate) fn add_variable(&mut self, location: &Location, key: &str, typ: Type) -> Result<()> {
-        if self.variables.contains_key(key) {
-            Err(SemanticError::name_redefined(location, key))
+        if let Some(previous) = self.variables.get(key) {
+            Err(SemanticError::name_redefined(
+                location,
+                key,
+                &previous.location,
+            ))
         } else {
             self.variables
                 .insert(key.to_string(), TypeInfo::new(location, typ));
             Ok(())
         }
     }
+    /// add_mutable_variable adds a variable can be assigned again, e.g. `mut x: int = 1;`
+    pub(crate) fn add_mutable_variable(
+        &mut self,
+        location: &Location,
+        key: &str,
+        typ: Type,
+    ) -> Result<()> {
+        self.add_variable(location, key, typ)?;
+        self.variables.get_mut(key).unwrap().mutable = true;
+        Ok(())
+    }
     pub(crate) fn lookup_variable(&self, location: &Location, k: &str) -> Result<TypeInfo> {
         let result = self.variables.get(k);
         match result {
@@ -315,8 +595,12 @@ impl TypeEnv {
     }
 
     pub(crate) fn add_type(&mut self, location: &Location, key: &str, typ: Type) -> Result<()> {
-        if self.types.contains_key(key) {
-            Err(SemanticError::name_redefined(location, key))
+        if let Some(previous) = self.types.get(key) {
+            Err(SemanticError::name_redefined(
+                location,
+                key,
+                &previous.location,
+            ))
         } else {
             self.types
                 .insert(key.to_string(), TypeInfo::new(location, typ));
@@ -345,6 +629,7 @@ impl TypeEnv {
 pub struct TypeInfo {
     pub location: Location,
     pub typ: Type,
+    pub mutable: bool,
 }
 
 impl TypeInfo {
@@ -352,6 +637,7 @@ impl TypeInfo {
         TypeInfo {
             location: location.clone(),
             typ,
+            mutable: false,
         }
     }
 }
@@ -402,8 +688,10 @@ impl ClassMembers {
 
 #[derive(Clone, Debug, PartialEq)]
 pub enum Type {
-    // TODO: complete definition
-    TraitType,
+    TraitType {
+        name: String,
+        members: ClassMembers,
+    },
     ClassType {
         name: String,
         parents: Vec<Type>,
@@ -412,6 +700,7 @@ pub enum Type {
         members: ClassMembers,
     },
     FunctionType(Vec<Type>, Box<Type>),
+    TupleType(Vec<Type>),
     FreeVar(usize),
 }
 
@@ -432,7 +721,7 @@ impl Type {
                 type_parameters,
                 ..
             } => match name.as_str() {
-                "void" | "int" | "bool" | "f64" | "string" => false,
+                "void" | "int" | "bool" | "f64" | "char" | "string" => false,
                 _ => {
                     for t in type_parameters {
                         if self.occurs(t) {
@@ -442,7 +731,8 @@ impl Type {
                     false
                 }
             },
-            TraitType => unimplemented!("trait type"),
+            TupleType(ts) => ts.into_iter().any(|t| self.occurs(t)),
+            TraitType { .. } => false,
             FreeVar(_) => self.clone() == t,
         }
     }
@@ -471,9 +761,22 @@ impl std::fmt::Display for Type {
                 }
                 write!(f, "")
             }
-            TraitType => unimplemented!("trait type"),
-            // FIXME: print format: `(int, int): int` not `<function>`
-            FunctionType(_params, _ret) => write!(f, "<function>"),
+            TraitType { name, .. } => write!(f, "{}", name),
+            FunctionType(params, ret) => {
+                write!(f, "(")?;
+                for (i, param) in params.iter().enumerate() {
+                    if i == params.len() - 1 {
+                        write!(f, "{}", param)?;
+                    } else {
+                        write!(f, "{}, ", param)?;
+                    }
+                }
+                write!(f, "): {}", ret)
+            }
+            TupleType(types) => {
+                let types: Vec<String> = types.iter().map(|t| t.to_string()).collect();
+                write!(f, "({})", types.join(", "))
+            }
             FreeVar(n) => write!(f, "'{}", n),
         }
     }